@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"aem/internal/config"
+	"aem/pkg/settings"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type settingValue struct {
+	Key    string `json:"key" yaml:"key"`
+	Value  string `json:"value" yaml:"value"`
+	Source string `json:"source" yaml:"source"`
+	Env    string `json:"env" yaml:"env"`
+	// Note warns that an environment variable overrides the stored value.
+	Note string `json:"note,omitempty" yaml:"note,omitempty"`
+}
+
+type settingValues struct {
+	Settings []settingValue `json:"settings" yaml:"settings"`
+}
+
+type validationResult struct {
+	Config string `json:"config" yaml:"config"`
+	Valid  bool   `json:"valid" yaml:"valid"`
+}
+
+type projectConfigOutput struct {
+	Config string                `json:"config" yaml:"config"`
+	Values *config.ProjectConfig `json:"values" yaml:"values"`
+}
+
+type resolvedValue struct {
+	Field  string `json:"field" yaml:"field"`
+	Value  string `json:"value" yaml:"value"`
+	Source string `json:"source" yaml:"source"`
+}
+
+type resolvedConfigOutput struct {
+	Files  []string        `json:"files" yaml:"files"`
+	Values []resolvedValue `json:"values" yaml:"values"`
+}
+
+func newSettingValue(value settings.Value) settingValue {
+	env, _, _ := settings.Describe(value.Key)
+	return settingValue{Key: value.Key, Value: value.Value, Source: value.Source, Env: env}
+}
+
+func newConfigCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage global settings and inspect project configs",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	schemaCmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema for aem.json",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			schema := config.ProjectConfigSchema()
+			data, err := json.MarshalIndent(schema, "", "  ")
+			if err != nil {
+				return err
+			}
+			return render(schema, func() {
+				fmt.Println(string(data))
+			})
+		},
+	}
+
+	validateCmd := &cobra.Command{
+		Use:   "validate [path]",
+		Short: "Validate the nearest project config or the given file",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configPath := ""
+			if len(args) == 1 {
+				configPath = args[0]
+			} else {
+				found, err := config.FindProjectConfig("")
+				if err != nil {
+					return err
+				}
+				configPath = found
+			}
+
+			if _, err := config.LoadProjectConfig(configPath); err != nil {
+				return err
+			}
+			return render(validationResult{Config: configPath, Valid: true}, func() {
+				fmt.Printf("%s is valid\n", configPath)
+			})
+		},
+	}
+
+	var resolvedOutput bool
+	showCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the nearest project config",
+		Long: "Print the nearest project config. With --resolved, print the effective config\n" +
+			"after merging inherited configs, together with the file each value came from.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configPath, err := config.FindProjectConfig("")
+			if err != nil {
+				return err
+			}
+
+			if !resolvedOutput {
+				projectConfig, err := config.LoadProjectConfig(configPath)
+				if err != nil {
+					return err
+				}
+				data, err := json.MarshalIndent(projectConfig, "", "  ")
+				if err != nil {
+					return err
+				}
+				return render(projectConfigOutput{Config: configPath, Values: projectConfig}, func() {
+					fmt.Printf("# %s\n%s\n", configPath, string(data))
+				})
+			}
+
+			resolved, err := config.ResolveProjectConfigFile(configPath)
+			if err != nil {
+				return err
+			}
+			output := resolvedConfigOutput{Files: resolved.Files, Values: []resolvedValue{}}
+			for _, value := range resolved.Values() {
+				output.Values = append(output.Values, resolvedValue{Field: value.Field, Value: value.Value, Source: value.Source})
+			}
+			return render(output, func() {
+				printResolvedConfig(output)
+			})
+		},
+	}
+	showCmd.Flags().BoolVar(&resolvedOutput, "resolved", false, "print the effective config with the source file of every value")
+
+	getCmd := &cobra.Command{
+		Use:   "get [key]",
+		Short: "Print the effective value of a global setting",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := settings.Load()
+			if err != nil {
+				return err
+			}
+			value, err := cfg.Get(args[0])
+			if err != nil {
+				return err
+			}
+			return render(newSettingValue(value), func() {
+				fmt.Println(value.Value)
+			})
+		},
+	}
+
+	setCmd := &cobra.Command{
+		Use:   "set [key] [value]",
+		Short: "Store a global setting in AEM_HOME/config.json",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := settings.Load()
+			if err != nil {
+				return err
+			}
+			if err := cfg.Set(args[0], args[1]); err != nil {
+				return err
+			}
+			if err := cfg.Save(); err != nil {
+				return err
+			}
+			return renderSetting(cfg, args[0], "")
+		},
+	}
+
+	unsetCmd := &cobra.Command{
+		Use:   "unset [key]",
+		Short: "Remove a global setting from AEM_HOME/config.json",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := settings.Load()
+			if err != nil {
+				return err
+			}
+			if err := cfg.Unset(args[0]); err != nil {
+				return err
+			}
+			if err := cfg.Save(); err != nil {
+				return err
+			}
+			return renderSetting(cfg, args[0], "")
+		},
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List global settings with their values and sources",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := settings.Load()
+			if err != nil {
+				return err
+			}
+
+			output := settingValues{Settings: []settingValue{}}
+			keyWidth, valueWidth := 0, 0
+			for _, value := range cfg.List() {
+				output.Settings = append(output.Settings, newSettingValue(value))
+				keyWidth = max(keyWidth, len(value.Key))
+				valueWidth = max(valueWidth, len(value.Value))
+			}
+			return render(output, func() {
+				for _, value := range output.Settings {
+					fmt.Printf("%-*s  %-*s  (%s, %s)\n", keyWidth, value.Key, valueWidth, value.Value, value.Source, value.Env)
+				}
+			})
+		},
+	}
+
+	configCmd.AddCommand(getCmd)
+	configCmd.AddCommand(setCmd)
+	configCmd.AddCommand(unsetCmd)
+	configCmd.AddCommand(listCmd)
+	configCmd.AddCommand(schemaCmd)
+	configCmd.AddCommand(validateCmd)
+	configCmd.AddCommand(showCmd)
+
+	return configCmd
+}
+
+// renderSetting reports the effective value of key after it was changed, with
+// a note when an environment variable overrides it. table prints the
+// confirmation, if any.
+func renderSetting(cfg *settings.Settings, key, confirmation string) error {
+	value, err := cfg.Get(key)
+	if err != nil {
+		return err
+	}
+	result := newSettingValue(value)
+	if result.Env != "" && os.Getenv(result.Env) != "" {
+		result.Note = result.Env + " is set in the environment and takes precedence"
+	}
+	return render(result, func() {
+		if confirmation != "" {
+			fmt.Println(confirmation)
+		}
+		if result.Note != "" {
+			fmt.Printf("Note: %s\n", result.Note)
+		}
+	})
+}
+
+func printResolvedConfig(resolved resolvedConfigOutput) {
+	fmt.Println("Config files (outermost first):")
+	for _, file := range resolved.Files {
+		fmt.Printf("  %s\n", displayPath(file))
+	}
+	fmt.Println()
+
+	values := resolved.Values
+	if len(values) == 0 {
+		fmt.Println("No values configured.")
+		return
+	}
+
+	fieldWidth, valueWidth := 0, 0
+	for _, value := range values {
+		fieldWidth = max(fieldWidth, len(value.Field))
+		valueWidth = max(valueWidth, len(value.Value))
+	}
+	for _, value := range values {
+		fmt.Printf("%-*s  %-*s  (%s)\n", fieldWidth, value.Field, valueWidth, value.Value, displayPath(value.Source))
+	}
+}
+
+// displayPath shortens paths below the working directory for readability.
+func displayPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
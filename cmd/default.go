@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"aem/pkg/settings"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultModules maps accepted module names to their settings key suffix.
+var defaultModules = map[string]string{
+	"node":   "node",
+	"java":   "java",
+	"jdk":    "java",
+	"gradle": "gradle",
+	"ruby":   "ruby",
+	"python": "python",
+}
+
+type defaultVersion struct {
+	Module  string `json:"module" yaml:"module"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+}
+
+func newDefaultCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "default [module] [version]",
+		Short: "Set the Node, Java, Gradle, Ruby or Python version used outside of any project",
+		Long: "Record a user-level default version that 'aem setup' and 'aem env' fall back to\n" +
+			"when no project config is found. Without a version the current default is printed.",
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			module, ok := defaultModules[args[0]]
+			if !ok {
+				return fmt.Errorf("%s module does not exist", args[0])
+			}
+			key := "defaults." + module
+
+			cfg, err := settings.Load()
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 1 {
+				result := defaultVersion{Module: module, Version: cfg.DefaultVersion(module)}
+				return render(result, func() {
+					if result.Version == "" {
+						fmt.Printf("%s: none\n", module)
+						return
+					}
+					fmt.Printf("%s: %s\n", module, result.Version)
+				})
+			}
+
+			version := strings.TrimPrefix(strings.TrimSpace(args[1]), "v")
+			if version == "" {
+				return fmt.Errorf("version must not be empty")
+			}
+			if err := cfg.Set(key, version); err != nil {
+				return err
+			}
+			if err := cfg.Save(); err != nil {
+				return err
+			}
+
+			return renderSetting(cfg, key, fmt.Sprintf("Default %s set to %s", module, version))
+		},
+	}
+}
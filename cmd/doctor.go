@@ -0,0 +1,539 @@
+package cmd
+
+import (
+	"aem/internal/android"
+	"aem/internal/environment"
+	"aem/internal/importer"
+	"aem/internal/platform"
+	"aem/pkg/progress"
+	"aem/pkg/settings"
+	"aem/pkg/state"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	checkOK    = "ok"
+	checkWarn  = "warn"
+	checkFail  = "fail"
+	checkFixed = "fixed"
+)
+
+type doctorReport struct {
+	AEMHome     string         `json:"aemHome" yaml:"aemHome"`
+	InstallDir  string         `json:"installDir" yaml:"installDir"`
+	CurrentRoot string         `json:"currentRoot" yaml:"currentRoot"`
+	Installed   map[string]int `json:"installed" yaml:"installed"`
+	Checks      []doctorCheck  `json:"checks" yaml:"checks"`
+}
+
+type doctorCheck struct {
+	Name    string   `json:"name" yaml:"name"`
+	Status  string   `json:"status" yaml:"status"`
+	Message string   `json:"message" yaml:"message"`
+	Details []string `json:"details,omitempty" yaml:"details,omitempty"`
+}
+
+// doctorContext carries what the individual checks inspect.
+type doctorContext struct {
+	aemHome    string
+	installDir string
+	settings   *settings.Settings
+	state      *state.State
+	fix        bool
+	// links maps each module to its active link, for links that resolve.
+	links map[string]string
+	// installing is the holder of the lock when another aem process is
+	// installing, whose staging directories are not leftovers.
+	installing string
+}
+
+func newDoctorCmd() *cobra.Command {
+	var fix bool
+
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Show local AEM state and health checks",
+		Long: "Check that the current runtime links resolve and run, that PATH, JAVA_HOME and\n" +
+			"ANDROID_HOME point at them, that Android licences are accepted, and that no\n" +
+			"leftovers from interrupted installs remain. --fix repairs what it can.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := runDoctor(fix)
+			if err != nil {
+				return err
+			}
+			return render(report, func() {
+				printDoctorReport(report)
+			})
+		},
+	}
+	doctorCmd.Flags().BoolVar(&fix, "fix", false, "repair problems that can be fixed automatically")
+
+	return doctorCmd
+}
+
+func runDoctor(fix bool) (*doctorReport, error) {
+	aemHome, err := fs.GetAEMHome()
+	if err != nil {
+		return nil, err
+	}
+	installDir, err := fs.GetInstallDir()
+	if err != nil {
+		return nil, err
+	}
+	currentRoot, err := fs.GetCurrentRoot()
+	if err != nil {
+		return nil, err
+	}
+	st, err := fs.GetState()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := settings.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := &doctorContext{
+		aemHome:    aemHome,
+		installDir: installDir,
+		settings:   cfg,
+		state:      st,
+		fix:        fix,
+		links:      make(map[string]string),
+	}
+	report := &doctorReport{
+		AEMHome:     aemHome,
+		InstallDir:  installDir,
+		CurrentRoot: currentRoot,
+		Installed: map[string]int{
+			"node":   countInstalledDirs(filepath.Join(installDir, "node")),
+			"java":   countInstalledDirs(filepath.Join(installDir, "java")),
+			"gradle": countInstalledDirs(filepath.Join(installDir, "gradle")),
+			"ruby":   countInstalledDirs(filepath.Join(installDir, "ruby")),
+			"python": countInstalledDirs(filepath.Join(installDir, "python")),
+		},
+	}
+
+	for _, module := range []string{"node", "java", "gradle", "ruby", "python", "android"} {
+		report.Checks = append(report.Checks, linkCheck(ctx, module))
+	}
+	report.Checks = append(report.Checks,
+		runtimeBinaryCheck(ctx, "node"),
+		runtimeBinaryCheck(ctx, "java"),
+		pathCheck(ctx),
+		homeVariableCheck(ctx, "JAVA_HOME", "java"),
+		homeVariableCheck(ctx, "ANDROID_HOME", "android"),
+		androidLicensesCheck(ctx),
+		versionsFileCheck(ctx),
+	)
+
+	unlock, err := lockLeftovers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	incomplete, err := incompleteInstallsCheck(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.Checks = append(report.Checks, incomplete, tmpCheck(ctx))
+
+	return report, nil
+}
+
+// lockLeftovers takes the lock before leftovers of interrupted installs are
+// looked for, as the staging directories of an install running elsewhere are
+// not leftovers. --fix waits for it; a plain check only probes it, and
+// records the holder in ctx.installing when another aem process has it.
+func lockLeftovers(ctx *doctorContext) (func(), error) {
+	if ctx.fix {
+		return fs.Lock()
+	}
+	unlock, owner, err := fs.TryLock()
+	if err != nil {
+		return nil, err
+	}
+	if unlock == nil {
+		ctx.installing = owner
+		return func() {}, nil
+	}
+	return unlock, nil
+}
+
+func printDoctorReport(report *doctorReport) {
+	fmt.Printf("AEM home: %s\n", report.AEMHome)
+	fmt.Printf("Install dir: %s\n", report.InstallDir)
+	fmt.Printf("Current links: %s\n", report.CurrentRoot)
+	fmt.Printf("Node installed: %d\n", report.Installed["node"])
+	fmt.Printf("Java installed: %d\n", report.Installed["java"])
+	fmt.Printf("Gradle installed: %d\n", report.Installed["gradle"])
+	fmt.Printf("Ruby installed: %d\n", report.Installed["ruby"])
+	fmt.Printf("Python installed: %d\n", report.Installed["python"])
+	for _, check := range report.Checks {
+		fmt.Printf("%-8s %s: %s\n", "["+check.Status+"]", check.Name, check.Message)
+		for _, detail := range check.Details {
+			fmt.Printf("         %s\n", detail)
+		}
+	}
+}
+
+// linkCheck verifies that the active link of module resolves to a directory
+// and, with --fix, removes it when it dangles.
+func linkCheck(ctx *doctorContext, module string) doctorCheck {
+	check := doctorCheck{Name: module + " link"}
+	linkPath := ctx.settings.SymlinkPath(module)
+
+	if _, err := os.Lstat(linkPath); err != nil {
+		check.Status = checkWarn
+		check.Message = "not set (" + linkPath + ")"
+		return check
+	}
+
+	target, err := filepath.EvalSymlinks(linkPath)
+	if err == nil {
+		if info, statErr := os.Stat(target); statErr == nil && info.IsDir() {
+			ctx.links[module] = linkPath
+			check.Status = checkOK
+			check.Message = linkPath + " -> " + target
+			return check
+		}
+	}
+
+	check.Status = checkFail
+	check.Message = linkPath + " does not resolve to an existing directory"
+	if !ctx.fix {
+		return check
+	}
+	if err := fs.RemoveAll(linkPath); err != nil {
+		check.Details = append(check.Details, "failed to remove link: "+err.Error())
+		return check
+	}
+	check.Status = checkFixed
+	check.Message = "removed dangling link " + linkPath + ", run 'aem setup' to recreate it"
+	return check
+}
+
+// runtimeBinaryCheck runs the active runtime of module and compares the
+// version it reports with the one its link points at.
+func runtimeBinaryCheck(ctx *doctorContext, module string) doctorCheck {
+	check := doctorCheck{Name: module + " runs"}
+	linkPath, ok := ctx.links[module]
+	if !ok {
+		check.Status = checkWarn
+		check.Message = "skipped, no active " + module + " link"
+		return check
+	}
+
+	expected, err := ctx.state.CurrentVersion(module)
+	if err != nil {
+		return failedCheck(check, err)
+	}
+	// External runtimes are linked under a name, compare their detected version
+	moduleDir := filepath.Join(ctx.installDir, module)
+	if name, ok := fs.ExternalName(moduleDir, expected); ok {
+		if runtimes, err := fs.ExternalRuntimes(moduleDir); err == nil && runtimes[name].Version != "" {
+			expected = runtimes[name].Version
+		}
+	}
+
+	reported, err := importer.DetectVersion(module, linkPath)
+	if err != nil {
+		return failedCheck(check, err)
+	}
+	return compareVersions(check, module, reported, expected)
+}
+
+// pathCheck verifies that each active runtime wins the PATH lookup, either
+// through its current bin directory or through the shims.
+func pathCheck(ctx *doctorContext) doctorCheck {
+	check := doctorCheck{Name: "PATH", Status: checkOK, Message: "current runtimes come first"}
+	shimDir := filepath.Join(ctx.aemHome, "shims")
+
+	tools := []struct {
+		module string
+		tool   string
+	}{
+		{"node", "node"},
+		{"java", "java"},
+		{"gradle", "gradle"},
+		{"ruby", "ruby"},
+		{"python", "python3"},
+		{"android", "adb"},
+	}
+	for _, t := range tools {
+		linkPath, ok := ctx.links[t.module]
+		if !ok {
+			continue
+		}
+
+		expected := environment.RuntimeBinDirs(runtimeLinkOptions(t.module, linkPath))
+		found, err := exec.LookPath(t.tool)
+		if err != nil {
+			check.Details = append(check.Details, fmt.Sprintf("%s is not on PATH, expected %s", t.tool, expected[0]))
+			continue
+		}
+
+		dir := filepath.Dir(found)
+		if sameDir(dir, shimDir) || containsDir(expected, dir) {
+			continue
+		}
+		check.Details = append(check.Details, fmt.Sprintf("%s resolves to %s instead of %s", t.tool, found, expected[0]))
+	}
+
+	if len(check.Details) > 0 {
+		check.Status = checkFail
+		check.Message = "other installs shadow the current runtimes, run eval \"$(aem env)\" or fix your shell profile"
+	}
+	return check
+}
+
+func homeVariableCheck(ctx *doctorContext, name, module string) doctorCheck {
+	check := doctorCheck{Name: name}
+	linkPath, ok := ctx.links[module]
+	if !ok {
+		check.Status = checkOK
+		check.Message = "no active " + module + " link"
+		return check
+	}
+
+	value := os.Getenv(name)
+	switch {
+	case value == "":
+		check.Status = checkWarn
+		check.Message = "not set, expected " + linkPath
+	case sameDir(value, linkPath):
+		check.Status = checkOK
+		check.Message = value
+	default:
+		check.Status = checkFail
+		check.Message = fmt.Sprintf("%s does not match %s", value, linkPath)
+	}
+	return check
+}
+
+func androidLicensesCheck(ctx *doctorContext) doctorCheck {
+	check := doctorCheck{Name: "android licences"}
+	if _, ok := ctx.links["android"]; !ok {
+		check.Status = checkOK
+		check.Message = "no active android link"
+		return check
+	}
+
+	service := android.NewService(log, ctx.installDir)
+	if service.LicensesAccepted() {
+		check.Status = checkOK
+		check.Message = "accepted"
+		return check
+	}
+
+	check.Status = checkWarn
+	check.Message = "not accepted"
+	if sdk, ok := service.ExternalSDK(); ok {
+		check.Message = fmt.Sprintf("not accepted, accept them with the SDK Manager of the SDK imported from %s", sdk.Source)
+		return check
+	}
+	if !ctx.fix {
+		return check
+	}
+	if err := service.AcceptLicenses(ctx.links["java"]); err != nil {
+		return failedCheck(check, err)
+	}
+	check.Status = checkFixed
+	check.Message = "accepted"
+	return check
+}
+
+// versionsFileCheck reports a legacy versions.json, which is no longer read,
+// and with --fix migrates it into the link state.
+func versionsFileCheck(ctx *doctorContext) doctorCheck {
+	check := doctorCheck{Name: legacyVersionsFileName, Status: checkOK, Message: "absent"}
+	if !legacyVersionsExists(ctx.aemHome) {
+		return check
+	}
+
+	check.Status = checkWarn
+	check.Message = "legacy file present, run 'aem migrate' to move it into the link state"
+	if !ctx.fix {
+		return check
+	}
+
+	messages, err := migrateLegacyVersions()
+	check.Details = messages
+	if err != nil {
+		return failedCheck(check, err)
+	}
+	check.Status = checkFixed
+	check.Message = "migrated"
+	return check
+}
+
+func incompleteInstallsCheck(ctx *doctorContext) (doctorCheck, error) {
+	check := doctorCheck{Name: "incomplete installs", Status: checkOK, Message: "none"}
+
+	info := platform.GetInfo()
+	roots := map[string]string{
+		filepath.Join(ctx.installDir, "node"):   info.RuntimeBinary("node"),
+		filepath.Join(ctx.installDir, "java"):   info.RuntimeBinary("java"),
+		filepath.Join(ctx.installDir, "gradle"): info.RuntimeBinary("gradle"),
+		filepath.Join(ctx.installDir, "ruby"):   info.RuntimeBinary("ruby"),
+		filepath.Join(ctx.installDir, "python"): info.RuntimeBinary("python"),
+	}
+	// An SDK imported from Android Studio is not aem's to repair
+	if _, external := android.NewService(log, ctx.installDir).ExternalSDK(); !external {
+		roots[filepath.Join(ctx.installDir, "android", "sdk", "cmdline-tools")] = "bin"
+	}
+	incomplete, err := fs.FindIncompleteInstalls(roots)
+	if err != nil {
+		return check, err
+	}
+	if len(incomplete) == 0 {
+		return check, nil
+	}
+	if ctx.installing != "" {
+		check.Message = fmt.Sprintf("%d in progress, aem is installing as %s", len(incomplete), ctx.installing)
+		for _, install := range incomplete {
+			check.Details = append(check.Details, install.Path+" (in progress)")
+		}
+		return check, nil
+	}
+
+	check.Status = checkWarn
+	check.Message = fmt.Sprintf("%d found, run 'aem doctor --fix' to repair them", len(incomplete))
+	if ctx.fix {
+		check.Status = checkFixed
+		check.Message = fmt.Sprintf("%d repaired", len(incomplete))
+	}
+
+	failed := 0
+	for _, install := range incomplete {
+		if !ctx.fix {
+			check.Details = append(check.Details, fmt.Sprintf("%s (%s)", install.Path, install.Reason))
+			continue
+		}
+		if err := fs.RepairIncompleteInstall(install); err != nil {
+			failed++
+			check.Details = append(check.Details, fmt.Sprintf("%s: repair failed: %v", install.Path, err))
+			continue
+		}
+		check.Details = append(check.Details, install.Path+": repaired")
+	}
+	if failed > 0 {
+		check.Status = checkFail
+		check.Message = fmt.Sprintf("%d of %d could not be repaired", failed, len(incomplete))
+	}
+
+	return check, nil
+}
+
+// tmpCheck reports anything left in AEM_HOME/tmp. It runs after the
+// incomplete install check, which already removes staging directories, with
+// the lock held by lockLeftovers.
+func tmpCheck(ctx *doctorContext) doctorCheck {
+	check := doctorCheck{Name: "tmp", Status: checkOK, Message: "empty"}
+	tmpDir := filepath.Join(ctx.aemHome, "tmp")
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil || len(entries) == 0 {
+		return check
+	}
+
+	var total int64
+	for _, entry := range entries {
+		path := filepath.Join(tmpDir, entry.Name())
+		size, _ := fs.DirSize(path)
+		total += size
+		check.Details = append(check.Details, fmt.Sprintf("%s (%s)", path, progress.HumanizeBytes(size)))
+	}
+	if ctx.installing != "" {
+		check.Message = fmt.Sprintf("%d entries using %s, aem is installing as %s", len(entries), progress.HumanizeBytes(total), ctx.installing)
+		return check
+	}
+	check.Status = checkWarn
+	check.Message = fmt.Sprintf("%d leftover entries from interrupted installs using %s", len(entries), progress.HumanizeBytes(total))
+	if !ctx.fix {
+		return check
+	}
+
+	for _, entry := range entries {
+		if err := fs.RemoveAll(filepath.Join(tmpDir, entry.Name())); err != nil {
+			return failedCheck(check, err)
+		}
+	}
+	check.Status = checkFixed
+	check.Details = nil
+	check.Message = fmt.Sprintf("removed %d leftover entries, freeing %s", len(entries), progress.HumanizeBytes(total))
+	return check
+}
+
+func failedCheck(check doctorCheck, err error) doctorCheck {
+	check.Status = checkFail
+	check.Message = err.Error()
+	return check
+}
+
+func compareVersions(check doctorCheck, name, reported, expected string) doctorCheck {
+	if reported == expected || strings.HasPrefix(expected, reported+".") || strings.HasPrefix(reported, expected+".") {
+		check.Status = checkOK
+		check.Message = reported
+		return check
+	}
+	check.Status = checkFail
+	check.Message = fmt.Sprintf("%s reports %s but the current link points at %s", name, reported, expected)
+	return check
+}
+
+func runtimeLinkOptions(module, linkPath string) environment.Options {
+	switch module {
+	case "node":
+		return environment.Options{NodeHome: linkPath}
+	case "java":
+		return environment.Options{JavaHome: linkPath}
+	case "gradle":
+		return environment.Options{GradleHome: linkPath}
+	case "ruby":
+		return environment.Options{RubyHome: linkPath}
+	case "python":
+		return environment.Options{PythonHome: linkPath}
+	default:
+		return environment.Options{AndroidHome: linkPath}
+	}
+}
+
+func sameDir(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	resolvedA, errA := filepath.EvalSymlinks(a)
+	resolvedB, errB := filepath.EvalSymlinks(b)
+	return errA == nil && errB == nil && resolvedA == resolvedB
+}
+
+func containsDir(dirs []string, dir string) bool {
+	for _, candidate := range dirs {
+		if sameDir(candidate, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func countInstalledDirs(path string) int {
+	// Runtimes registered from other managers count too
+	versions, err := fs.InstalledVersions(path)
+	if err != nil {
+		return 0
+	}
+	return len(versions)
+}
+
+func legacyVersionsExists(aemHome string) bool {
+	return fs.Exists(filepath.Join(aemHome, legacyVersionsFileName))
+}
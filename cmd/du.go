@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"aem/pkg/progress"
+	"aem/pkg/settings"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// androidPackageDepth is how many directory levels below the SDK root name a
+// single sdkmanager package, e.g. "ndk;25.1.8937393" or
+// "system-images;android-34;google_apis;x86_64". Directories not listed are
+// packages themselves, e.g. "platform-tools".
+var androidPackageDepth = map[string]int{
+	"build-tools":   1,
+	"cmake":         1,
+	"cmdline-tools": 1,
+	"ndk":           1,
+	"platforms":     1,
+	"sources":       1,
+	"system-images": 3,
+}
+
+type duEntry struct {
+	Area     string `json:"area" yaml:"area"`
+	Module   string `json:"module,omitempty" yaml:"module,omitempty"`
+	Name     string `json:"name" yaml:"name"`
+	Path     string `json:"path" yaml:"path"`
+	Size     int64  `json:"size" yaml:"size"`
+	External bool   `json:"external,omitempty" yaml:"external,omitempty"`
+}
+
+type duArea struct {
+	Name string `json:"name" yaml:"name"`
+	Path string `json:"path" yaml:"path"`
+	Size int64  `json:"size" yaml:"size"`
+}
+
+type duReport struct {
+	Total   int64     `json:"total" yaml:"total"`
+	Areas   []duArea  `json:"areas" yaml:"areas"`
+	Entries []duEntry `json:"entries" yaml:"entries"`
+}
+
+func newDuCmd() *cobra.Command {
+	var jsonOutput bool
+
+	duCmd := &cobra.Command{
+		Use:   "du",
+		Short: "Show disk usage per runtime version, Android package, tmp and cache",
+		Args:  cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if jsonOutput {
+				outputFormat = outputJSON
+			}
+			return applyOutputFormat(cmd)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := diskUsage()
+			if err != nil {
+				return err
+			}
+			return render(report, func() {
+				printDiskUsage(report)
+			})
+		},
+	}
+	duCmd.Flags().BoolVar(&jsonOutput, "json", false, "shorthand for --output json")
+
+	return duCmd
+}
+
+func diskUsage() (*duReport, error) {
+	aemHome, err := fs.GetAEMHome()
+	if err != nil {
+		return nil, err
+	}
+	installDir, err := fs.GetInstallDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := settings.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &duReport{Areas: []duArea{}, Entries: []duEntry{}}
+	areas := []struct {
+		name    string
+		path    string
+		collect func(path string) ([]duEntry, error)
+	}{
+		{"sys_installed", installDir, installedUsage},
+		{"tmp", filepath.Join(aemHome, "tmp"), func(path string) ([]duEntry, error) { return childUsage("tmp", "", path) }},
+		{"cache", cfg.String("cache.dir"), func(path string) ([]duEntry, error) { return childUsage("cache", "", path) }},
+	}
+	for _, area := range areas {
+		entries, err := area.collect(area.path)
+		if err != nil {
+			return nil, err
+		}
+
+		var size int64
+		for _, entry := range entries {
+			size += entry.Size
+		}
+		report.Areas = append(report.Areas, duArea{Name: area.name, Path: area.path, Size: size})
+		report.Entries = append(report.Entries, entries...)
+		report.Total += size
+	}
+
+	sort.SliceStable(report.Entries, func(i, j int) bool {
+		return report.Entries[i].Size > report.Entries[j].Size
+	})
+	return report, nil
+}
+
+// installedUsage reports every version of every module, and the Android SDK
+// per package.
+func installedUsage(installDir string) ([]duEntry, error) {
+	modules, err := os.ReadDir(installDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []duEntry
+	for _, module := range modules {
+		if strings.HasPrefix(module.Name(), ".") || !module.IsDir() {
+			continue
+		}
+		moduleDir := filepath.Join(installDir, module.Name())
+
+		var found []duEntry
+		if module.Name() == "android" {
+			found, err = androidUsage(filepath.Join(moduleDir, "sdk"))
+		} else {
+			found, err = childUsage("sys_installed", module.Name(), moduleDir)
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, found...)
+	}
+	return entries, nil
+}
+
+func androidUsage(sdkRoot string) ([]duEntry, error) {
+	if info, err := os.Lstat(sdkRoot); err != nil {
+		return nil, nil
+	} else if info.Mode()&os.ModeSymlink != 0 {
+		return []duEntry{{Area: "sys_installed", Module: "android", Name: "sdk", Path: sdkRoot, External: true}}, nil
+	}
+
+	var entries []duEntry
+	var walk func(dir string, parts []string, depth int) error
+	walk = func(dir string, parts []string, depth int) error {
+		if depth == 0 {
+			size, err := fs.DirSize(dir)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, duEntry{Area: "sys_installed", Module: "android", Name: strings.Join(parts, ";"), Path: dir, Size: size})
+			return nil
+		}
+
+		children, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			if strings.HasPrefix(child.Name(), ".") {
+				continue
+			}
+			if !child.IsDir() {
+				// Loose files such as package.xml count towards the parent
+				if info, err := child.Info(); err == nil {
+					entries = append(entries, duEntry{Area: "sys_installed", Module: "android", Name: strings.Join(parts, ";"), Path: dir, Size: info.Size()})
+				}
+				continue
+			}
+			next := append(append([]string{}, parts...), child.Name())
+			if err := walk(filepath.Join(dir, child.Name()), next, depth-1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	packages, err := os.ReadDir(sdkRoot)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range packages {
+		if strings.HasPrefix(pkg.Name(), ".") || !pkg.IsDir() {
+			continue
+		}
+		if err := walk(filepath.Join(sdkRoot, pkg.Name()), []string{pkg.Name()}, androidPackageDepth[pkg.Name()]); err != nil {
+			return nil, err
+		}
+	}
+	return mergeEntries(entries), nil
+}
+
+// childUsage reports one entry per file or directory in dir. Links to
+// runtimes installed elsewhere are listed as external without a size.
+func childUsage(area, module, dir string) ([]duEntry, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	children, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []duEntry
+	for _, child := range children {
+		if strings.HasPrefix(child.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, child.Name())
+		entry := duEntry{Area: area, Module: module, Name: child.Name(), Path: path}
+		if child.Type()&os.ModeSymlink != 0 {
+			entry.External = true
+		} else if entry.Size, err = fs.DirSize(path); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// mergeEntries folds entries with the same name, e.g. loose files next to
+// package directories, into one.
+func mergeEntries(entries []duEntry) []duEntry {
+	var merged []duEntry
+	index := make(map[string]int)
+	for _, entry := range entries {
+		if i, ok := index[entry.Name]; ok {
+			merged[i].Size += entry.Size
+			continue
+		}
+		index[entry.Name] = len(merged)
+		merged = append(merged, entry)
+	}
+	return merged
+}
+
+func printDiskUsage(report *duReport) {
+	for _, entry := range report.Entries {
+		name := entry.Name
+		if entry.Module != "" {
+			name = entry.Module + " " + name
+		}
+		size := progress.HumanizeBytes(entry.Size)
+		if entry.External {
+			size = "external"
+		}
+		fmt.Printf("%10s  %-14s %s\n", size, entry.Area, name)
+	}
+
+	fmt.Println()
+	for _, area := range report.Areas {
+		fmt.Printf("%10s  %-14s %s\n", progress.HumanizeBytes(area.Size), area.Name, area.Path)
+	}
+	fmt.Printf("%10s  total\n", progress.HumanizeBytes(report.Total))
+}
@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"aem/internal/config"
+	"aem/internal/environment"
+	"aem/pkg/settings"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+type environmentOutput struct {
+	Variables []environment.Variable `json:"variables" yaml:"variables"`
+}
+
+func newEnvCmd() *cobra.Command {
+	var profile string
+	var shell string
+
+	envCmd := &cobra.Command{
+		Use:   "env",
+		Short: "Print shell statements that activate the current runtimes and project env",
+		Long: "Print shell statements that activate the current runtimes and the env and path\n" +
+			"entries of the nearest project config. Typical usage: eval \"$(aem env)\"",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			vars, err := resolveEnvironment(profile)
+			if err != nil {
+				return err
+			}
+
+			if shell == "" {
+				shell = environment.DefaultShell()
+			}
+			output, err := environment.Format(vars, shell)
+			if err != nil {
+				return err
+			}
+			return render(environmentOutput{Variables: append([]environment.Variable{}, vars...)}, func() {
+				fmt.Print(output)
+			})
+		},
+	}
+	envCmd.Flags().StringVarP(&profile, "profile", "p", "", "apply a profile from aem.json (defaults to $AEM_PROFILE)")
+	envCmd.Flags().StringVar(&shell, "shell", "", "output syntax: posix, fish, powershell or cmd")
+
+	return envCmd
+}
+
+func newExecCmd() *cobra.Command {
+	var profile string
+
+	execCmd := &cobra.Command{
+		Use:   "exec [command] [args...]",
+		Short: "Run a command with the current runtimes and project env applied",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyEnvironment(profile); err != nil {
+				return err
+			}
+			return runAttached(args[0], args[1:]...)
+		},
+	}
+	execCmd.Flags().StringVarP(&profile, "profile", "p", "", "apply a profile from aem.json (defaults to $AEM_PROFILE)")
+	execCmd.Flags().SetInterspersed(false)
+
+	return execCmd
+}
+
+func newShellCmd() *cobra.Command {
+	var profile string
+
+	shellCmd := &cobra.Command{
+		Use:   "shell",
+		Short: "Start a subshell with the current runtimes and project env applied",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyEnvironment(profile); err != nil {
+				return err
+			}
+			os.Setenv("AEM_SHELL", "1")
+
+			if !machineOutput() {
+				fmt.Println("Entering aem shell, type 'exit' to leave")
+			}
+			return runAttached(environment.ShellCommand())
+		},
+	}
+	shellCmd.Flags().StringVarP(&profile, "profile", "p", "", "apply a profile from aem.json (defaults to $AEM_PROFILE)")
+
+	return shellCmd
+}
+
+func resolveEnvironment(profile string) ([]environment.Variable, error) {
+	opts, err := environmentOptions(profile)
+	if err != nil {
+		return nil, err
+	}
+	return environment.Variables(opts, os.Environ())
+}
+
+// applyEnvironment updates the aem process environment so that the child
+// inherits it and command lookup uses the new PATH.
+func applyEnvironment(profile string) error {
+	vars, err := resolveEnvironment(profile)
+	if err != nil {
+		return err
+	}
+	for _, v := range vars {
+		if err := os.Setenv(v.Name, v.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func environmentOptions(profile string) (environment.Options, error) {
+	aemHome, err := fs.GetAEMHome()
+	if err != nil {
+		return environment.Options{}, err
+	}
+
+	opts := environment.Options{AEMHome: aemHome}
+	links := []struct {
+		module string
+		target *string
+	}{
+		{"node", &opts.NodeHome},
+		{"java", &opts.JavaHome},
+		{"gradle", &opts.GradleHome},
+		{"ruby", &opts.RubyHome},
+		{"python", &opts.PythonHome},
+		{"android", &opts.AndroidHome},
+	}
+	for _, link := range links {
+		linkPath, err := resolveRuntimeSymlinkPath(link.module)
+		if err != nil {
+			return environment.Options{}, err
+		}
+		if fs.Exists(linkPath) {
+			*link.target = linkPath
+		}
+	}
+	for _, def := range definedRuntimes() {
+		linkPath, err := resolveRuntimeSymlinkPath(def.Name)
+		if err != nil {
+			return environment.Options{}, err
+		}
+		if fs.Exists(linkPath) {
+			opts.Tools = append(opts.Tools, def.ToolHome(linkPath))
+		}
+	}
+
+	if _, err := config.FindProjectConfig(""); err != nil {
+		log.Debug("No project config applied: %v", err)
+		return applyDefaultVersions(opts)
+	}
+
+	resolved, err := config.ResolveProjectConfig("")
+	if err != nil {
+		return environment.Options{}, err
+	}
+	projectConfig, err := resolved.Config.WithProfile(config.ResolveProfile(profile))
+	if err != nil {
+		return environment.Options{}, err
+	}
+
+	opts.Project = projectConfig
+	opts.ProjectDir = resolved.Dir()
+	return opts, nil
+}
+
+// applyDefaultVersions points the runtime homes at the installed default
+// versions outside a project, keeping the current links for anything else.
+func applyDefaultVersions(opts environment.Options) (environment.Options, error) {
+	cfg, err := settings.Load()
+	if err != nil {
+		return environment.Options{}, err
+	}
+	installDir, err := fs.GetInstallDir()
+	if err != nil {
+		return environment.Options{}, err
+	}
+
+	defaults := []struct {
+		module string
+		target *string
+	}{
+		{"node", &opts.NodeHome},
+		{"java", &opts.JavaHome},
+		{"gradle", &opts.GradleHome},
+		{"ruby", &opts.RubyHome},
+		{"python", &opts.PythonHome},
+	}
+	for _, d := range defaults {
+		version := cfg.DefaultVersion(d.module)
+		if version == "" {
+			continue
+		}
+		versionPath := fs.LatestInstalled(filepath.Join(installDir, d.module), version)
+		if versionPath == "" {
+			log.Debug("Default %s %s is not installed, run 'aem setup' outside a project to install it", d.module, version)
+			continue
+		}
+		*d.target = versionPath
+	}
+	return opts, nil
+}
+
+func runAttached(name string, args ...string) error {
+	child := exec.Command(name, args...)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+
+	if err := child.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitErr.ExitCode())
+		}
+		return err
+	}
+	return nil
+}
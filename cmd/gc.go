@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	gradlesvc "aem/internal/gradle"
+	javasvc "aem/internal/java"
+	nodesvc "aem/internal/node"
+	pythonsvc "aem/internal/python"
+	rubysvc "aem/internal/ruby"
+	"aem/internal/runtimes"
+	"aem/pkg/errors"
+	"aem/pkg/filesystem"
+	"aem/pkg/settings"
+	"aem/pkg/state"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	gcRemoved     = "removed"
+	gcWouldRemove = "would remove"
+	gcKept        = "kept"
+)
+
+type gcEntry struct {
+	Module   string    `json:"module" yaml:"module"`
+	Version  string    `json:"version" yaml:"version"`
+	Status   string    `json:"status" yaml:"status"`
+	Reason   string    `json:"reason,omitempty" yaml:"reason,omitempty"`
+	LastUsed time.Time `json:"lastUsed" yaml:"lastUsed"`
+}
+
+type gcReport struct {
+	Entries []gcEntry `json:"entries" yaml:"entries"`
+}
+
+// uninstaller is implemented by the node and java services.
+type uninstaller interface {
+	CanUninstall(version string) error
+	Uninstall(version string) error
+}
+
+func newGCCmd() *cobra.Command {
+	var (
+		dryRun    bool
+		olderThan string
+	)
+
+	gcCmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Remove runtime versions no known project uses",
+		Long: "Remove Node and Java installs that are not referenced by any project 'aem setup'\n" +
+			"has run in, not the active or default version, and not linked from elsewhere.\n" +
+			"--older-than only removes installs that have not been used for that long.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			age, err := parseAge(olderThan)
+			if err != nil {
+				return err
+			}
+			report, err := runGC(dryRun, age)
+			if err != nil {
+				return err
+			}
+			return render(report, func() {
+				printGCReport(report)
+			})
+		},
+	}
+	gcCmd.Flags().BoolVar(&dryRun, "dry-run", false, "only list what would be removed")
+	gcCmd.Flags().StringVar(&olderThan, "older-than", "", "only remove installs unused for this long, e.g. 30d, 2w or 12h")
+
+	return gcCmd
+}
+
+func runGC(dryRun bool, olderThan time.Duration) (*gcReport, error) {
+	installDir, err := fs.GetInstallDir()
+	if err != nil {
+		return nil, err
+	}
+	st, err := fs.GetState()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := settings.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep setup from selecting a version while it is being removed
+	unlock, err := fs.Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	usage, err := st.Usage()
+	if err != nil {
+		return nil, err
+	}
+
+	// referencedBy maps usage keys to the project configs that use them
+	referencedBy := make(map[string][]string)
+	for configPath, project := range usage.Projects {
+		if !fs.Exists(configPath) {
+			log.Debug("Forgetting removed project %s", configPath)
+			if !dryRun {
+				if err := st.ForgetProject(configPath); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		for module, version := range project.Runtimes {
+			key := state.UsageKey(module, version)
+			referencedBy[key] = append(referencedBy[key], configPath)
+		}
+	}
+
+	report := &gcReport{Entries: []gcEntry{}}
+	services := map[string]uninstaller{
+		"node":   nodesvc.NewService(log, installDir),
+		"java":   javasvc.NewService(log, installDir),
+		"gradle": gradlesvc.NewService(log, installDir),
+		"ruby":   rubysvc.NewService(log, installDir),
+		"python": pythonsvc.NewService(log, installDir),
+	}
+	modules := []string{"node", "java", "gradle", "ruby", "python"}
+	for _, def := range definedRuntimes() {
+		services[def.Name] = runtimes.NewService(log, installDir, def)
+		modules = append(modules, def.Name)
+	}
+	for _, module := range modules {
+		moduleDir := filepath.Join(installDir, module)
+		defaultPath := ""
+		if version := cfg.DefaultVersion(module); version != "" {
+			defaultPath = fs.LatestInstalled(moduleDir, version)
+		}
+
+		entries, err := os.ReadDir(moduleDir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.NewFileSystemError("failed to read "+moduleDir, err)
+		}
+		for _, dirEntry := range entries {
+			name := dirEntry.Name()
+			if strings.HasPrefix(name, ".") || filesystem.IsInstallBackup(name) || !dirEntry.IsDir() {
+				// Links registered with 'aem link' or 'aem import' are not ours
+				// to remove, and doctor repairs interrupted switches
+				continue
+			}
+
+			entry := gcEntry{Module: module, Version: name, Status: gcKept}
+			key := state.UsageKey(module, name)
+			entry.LastUsed = usage.Installs[key]
+			if entry.LastUsed.IsZero() {
+				if info, err := dirEntry.Info(); err == nil {
+					entry.LastUsed = info.ModTime().UTC()
+				}
+			}
+
+			switch {
+			case len(referencedBy[key]) > 0:
+				sort.Strings(referencedBy[key])
+				entry.Reason = "used by " + strings.Join(referencedBy[key], ", ")
+			case defaultPath != "" && filepath.Base(defaultPath) == name:
+				entry.Reason = "default version"
+			case olderThan > 0 && time.Since(entry.LastUsed) < olderThan:
+				entry.Reason = "used " + formatAge(time.Since(entry.LastUsed)) + " ago"
+			default:
+				entry.Status, entry.Reason, err = collect(services[module], st, module, name, dryRun)
+				if err != nil {
+					return nil, err
+				}
+			}
+			report.Entries = append(report.Entries, entry)
+		}
+	}
+
+	return report, nil
+}
+
+// collect removes one install, reporting the Uninstall safety check as the
+// reason it was kept.
+func collect(service uninstaller, st *state.State, module, version string, dryRun bool) (string, string, error) {
+	check := service.CanUninstall
+	if !dryRun {
+		check = service.Uninstall
+	}
+
+	if err := check(version); err != nil {
+		var aemErr *errors.AEMError
+		if stderrors.As(err, &aemErr) && aemErr.Type == "UNINSTALL_ERROR" {
+			return gcKept, aemErr.Message, nil
+		}
+		return "", "", err
+	}
+
+	if dryRun {
+		return gcWouldRemove, "", nil
+	}
+	if err := st.ForgetInstall(module, version); err != nil {
+		log.Debug("Failed to forget %s %s: %v", module, version, err)
+	}
+	return gcRemoved, "", nil
+}
+
+func printGCReport(report *gcReport) {
+	removed := 0
+	for _, entry := range report.Entries {
+		if entry.Status == gcKept {
+			fmt.Printf("kept          %s %s: %s\n", entry.Module, entry.Version, entry.Reason)
+			continue
+		}
+		removed++
+		fmt.Printf("%-13s %s %s (last used %s)\n", entry.Status, entry.Module, entry.Version, entry.LastUsed.Local().Format("2006-01-02"))
+	}
+	if removed == 0 {
+		fmt.Println("Nothing to remove")
+	}
+}
+
+// parseAge accepts time.ParseDuration values plus a "d" (days) or "w"
+// (weeks) suffix.
+func parseAge(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number := strings.TrimSuffix(value, suffix); number != value {
+			n, err := strconv.Atoi(number)
+			if err != nil || n < 0 {
+				break
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	age, err := time.ParseDuration(value)
+	if err != nil || age < 0 {
+		return 0, errors.NewValidationError(fmt.Sprintf("invalid duration %q (expected e.g. 30d, 2w or 12h)", value))
+	}
+	return age, nil
+}
+
+func formatAge(age time.Duration) string {
+	if days := int(age.Hours() / 24); days > 0 {
+		return fmt.Sprintf("%dd", days)
+	}
+	return age.Round(time.Minute).String()
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"aem/internal/importer"
+	"aem/pkg/errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	importLinked  = "linked"
+	importCopied  = "copied"
+	importExists  = "exists"
+	importPlanned = "planned"
+)
+
+type importResult struct {
+	importer.Install `yaml:",inline"`
+	Target           string `json:"target" yaml:"target"`
+	Status           string `json:"status" yaml:"status"`
+}
+
+type importReport struct {
+	Imports []importResult `json:"imports" yaml:"imports"`
+}
+
+func newImportCmd() *cobra.Command {
+	var (
+		copyInstalls bool
+		dryRun       bool
+		home         string
+	)
+
+	importCmd := &cobra.Command{
+		Use:   "import [source...]",
+		Short: "Reuse runtimes installed by nvm, fnm, sdkman, asdf, rbenv, pyenv or Android Studio",
+		Long: "Discover runtimes installed by other version managers and register them in\n" +
+			"sys_installed, so 'aem use' and project setup can select them without a download.\n" +
+			"Installs are linked in place unless --copy is given.\n\n" +
+			"Sources: " + strings.Join(importer.SourceNames(), ", "),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, name := range args {
+				if !containsSource(name) {
+					return errors.NewValidationError(fmt.Sprintf("unknown import source %q (expected one of %s)", name, strings.Join(importer.SourceNames(), ", ")))
+				}
+			}
+
+			installDir, err := fs.GetInstallDir()
+			if err != nil {
+				return err
+			}
+			imports := importer.NewService(log, installDir)
+			if home != "" {
+				imports.SetHome(home)
+			}
+
+			report := importReport{Imports: []importResult{}}
+			for _, install := range imports.Discover(args...) {
+				result, err := importInstall(imports, install, copyInstalls, dryRun)
+				if err != nil {
+					return err
+				}
+				report.Imports = append(report.Imports, result)
+			}
+
+			return render(report, func() {
+				printImportReport(report)
+			})
+		},
+	}
+	importCmd.Flags().BoolVar(&copyInstalls, "copy", false, "copy installs into sys_installed instead of linking them")
+	importCmd.Flags().BoolVar(&dryRun, "dry-run", false, "only list what would be imported")
+	importCmd.Flags().StringVar(&home, "home", "", "look for installs under this directory instead of the home directory, ignoring NVM_DIR, SDKMAN_DIR and the like")
+
+	return importCmd
+}
+
+func importInstall(imports *importer.Service, install importer.Install, copyInstalls, dryRun bool) (importResult, error) {
+	result := importResult{Install: install}
+	if dryRun {
+		result.Status = importPlanned
+		return result, nil
+	}
+
+	var (
+		added bool
+		err   error
+	)
+	if copyInstalls {
+		result.Target, added, err = imports.Copy(install)
+		result.Status = importCopied
+	} else {
+		result.Target, added, err = imports.Register(install)
+		result.Status = importLinked
+	}
+	if err != nil {
+		return result, err
+	}
+	if !added {
+		result.Status = importExists
+	}
+	return result, nil
+}
+
+func printImportReport(report importReport) {
+	if len(report.Imports) == 0 {
+		fmt.Println("No installs found to import")
+		return
+	}
+
+	for _, result := range report.Imports {
+		switch result.Status {
+		case importPlanned:
+			fmt.Printf("%s %s from %s (%s)\n", result.Module, result.Version, result.Source, result.Path)
+		case importExists:
+			fmt.Printf("%s %s from %s: already in %s, skipped\n", result.Module, result.Version, result.Source, result.Target)
+		default:
+			fmt.Printf("%s %s from %s: %s to %s\n", result.Module, result.Version, result.Source, result.Status, result.Target)
+		}
+	}
+}
+
+func containsSource(name string) bool {
+	for _, source := range importer.SourceNames() {
+		if source == name {
+			return true
+		}
+	}
+	return false
+}
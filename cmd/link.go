@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"aem/internal/importer"
+	"aem/pkg/errors"
+	"aem/pkg/filesystem"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+type linkedRuntime struct {
+	Module                     string `json:"module" yaml:"module"`
+	filesystem.ExternalRuntime `yaml:",inline"`
+}
+
+type linkedRuntimes struct {
+	Runtimes []linkedRuntime `json:"runtimes" yaml:"runtimes"`
+}
+
+type unlinkedRuntime struct {
+	Module string `json:"module" yaml:"module"`
+	Name   string `json:"name" yaml:"name"`
+}
+
+func newLinkCmd() *cobra.Command {
+	var remove bool
+
+	linkCmd := &cobra.Command{
+		Use:   "link [module] [name] [path]",
+		Short: "Register a Node, Java, Gradle, Ruby or Python installation aem did not install",
+		Long: "Make an existing installation, such as a system JDK, selectable by 'aem use' and\n" +
+			"aem.json under name. The path must contain bin/node, bin/java, bin/ruby or\n" +
+			"bin/python3, which is run to detect its version, or be a Gradle distribution. Linked runtimes are never deleted\n" +
+			"by aem; --remove only unregisters them. Without arguments the linked runtimes are\n" +
+			"listed.",
+		Args: cobra.RangeArgs(0, 3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			installDir, err := fs.GetInstallDir()
+			if err != nil {
+				return err
+			}
+			if len(args) == 0 {
+				return listLinkedRuntimes(installDir)
+			}
+
+			module, ok := defaultModules[args[0]]
+			if !ok {
+				return fmt.Errorf("%s module does not exist", args[0])
+			}
+			imports := importer.NewService(log, installDir)
+
+			if remove {
+				if len(args) != 2 {
+					return errors.NewValidationError("usage: aem link --remove <module> <name>")
+				}
+				if err := imports.Unlink(module, args[1]); err != nil {
+					return err
+				}
+				result := unlinkedRuntime{Module: module, Name: args[1]}
+				return render(result, func() {
+					fmt.Printf("Unregistered %s %s\n", module, args[1])
+				})
+			}
+
+			if len(args) != 3 {
+				return errors.NewValidationError("usage: aem link <module> <name> <path>")
+			}
+			external, err := imports.Link(module, args[1], args[2])
+			if err != nil {
+				return err
+			}
+			return render(linkedRuntime{Module: module, ExternalRuntime: external}, func() {
+				fmt.Printf("Linked %s %s (%s) -> %s\n", module, external.Name, external.Version, external.Path)
+				fmt.Printf("Select it with 'aem use %s %s' or \"%s\": \"%s\" in aem.json\n", module, external.Name, module, external.Name)
+			})
+		},
+	}
+	linkCmd.Flags().BoolVar(&remove, "remove", false, "unregister a linked runtime, leaving its files in place")
+
+	return linkCmd
+}
+
+func listLinkedRuntimes(installDir string) error {
+	report := linkedRuntimes{Runtimes: []linkedRuntime{}}
+	for _, module := range []string{"android", "gradle", "java", "node", "python", "ruby"} {
+		runtimes, err := fs.ExternalRuntimes(filepath.Join(installDir, module))
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(runtimes))
+		for name := range runtimes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			report.Runtimes = append(report.Runtimes, linkedRuntime{Module: module, ExternalRuntime: runtimes[name]})
+		}
+	}
+
+	return render(report, func() {
+		if len(report.Runtimes) == 0 {
+			fmt.Println("No linked runtimes")
+			return
+		}
+		for _, runtime := range report.Runtimes {
+			fmt.Printf("%s %s (%s, %s) -> %s\n", runtime.Module, runtime.Name, runtime.Version, runtime.Source, runtime.Path)
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"aem/internal/importer"
+	javasvc "aem/internal/java"
+	nodesvc "aem/internal/node"
+	"aem/pkg/errors"
+	"aem/pkg/state"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const legacyVersionsFileName = "versions.json"
+
+// legacyVersions is the layout of the versions.json file older releases
+// tracked the active runtimes in.
+type legacyVersions struct {
+	Node string `json:"node,omitempty"`
+	Java string `json:"java,omitempty"`
+}
+
+type migrateReport struct {
+	Steps []string `json:"steps" yaml:"steps"`
+}
+
+func newMigrateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate",
+		Short: "Move the runtimes recorded in a legacy versions.json into the link state",
+		Long: "Activate the Node and Java versions recorded in AEM_HOME/versions.json, using\n" +
+			"installs from sys_installed, nvm or sdkman, then back the file up.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			messages, err := migrateLegacyVersions()
+			if err != nil && machineOutput() {
+				return err
+			}
+			report := migrateReport{Steps: append([]string{}, messages...)}
+			if renderErr := render(report, func() {
+				for _, message := range report.Steps {
+					fmt.Println(message)
+				}
+			}); renderErr != nil {
+				return renderErr
+			}
+			return err
+		},
+	}
+}
+
+// migrateLegacyVersions returns one message per step it took.
+func migrateLegacyVersions() ([]string, error) {
+	aemHome, err := fs.GetAEMHome()
+	if err != nil {
+		return nil, err
+	}
+	installDir, err := fs.GetInstallDir()
+	if err != nil {
+		return nil, err
+	}
+	st, err := fs.GetState()
+	if err != nil {
+		return nil, err
+	}
+
+	versionsPath := filepath.Join(aemHome, legacyVersionsFileName)
+	data, err := os.ReadFile(versionsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{"No " + legacyVersionsFileName + " found, nothing to migrate"}, nil
+		}
+		return nil, errors.NewFileSystemError("failed to read "+versionsPath, err)
+	}
+
+	var legacy legacyVersions
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, errors.NewFileSystemError("failed to parse "+versionsPath, err)
+	}
+
+	var messages []string
+	imports := importer.NewService(log, installDir)
+	for _, entry := range []struct {
+		module  string
+		version string
+	}{
+		{"node", legacy.Node},
+		{"java", legacy.Java},
+	} {
+		message, err := migrateRuntime(st, imports, installDir, entry.module, strings.TrimSpace(entry.version))
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, message)
+	}
+
+	backupPath := versionsPath + ".bak"
+	if fs.Exists(backupPath) {
+		backupPath = fmt.Sprintf("%s.%s.bak", versionsPath, time.Now().Format("20060102150405"))
+	}
+	if err := fs.Move(versionsPath, backupPath); err != nil {
+		return messages, err
+	}
+	messages = append(messages, "Backed up "+versionsPath+" to "+backupPath)
+
+	return messages, nil
+}
+
+func migrateRuntime(st *state.State, imports *importer.Service, installDir, module, version string) (string, error) {
+	if version == "" || version == "no current version" {
+		return fmt.Sprintf("%s: nothing recorded", module), nil
+	}
+
+	active, err := st.CurrentVersion(module)
+	if err != nil {
+		return "", err
+	}
+	if active != "" {
+		return fmt.Sprintf("%s: %s is already active, keeping it", module, active), nil
+	}
+
+	versionPath := fs.LatestInstalled(filepath.Join(installDir, module), version)
+	if versionPath == "" {
+		install, ok := imports.Find(module, version)
+		if !ok {
+			return fmt.Sprintf("%s: %s is not installed, run 'aem install %s %s'", module, version, module, version), nil
+		}
+		versionPath, _, err = imports.Register(install)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	symlinkPath, err := resolveRuntimeSymlinkPath(module)
+	if err != nil {
+		return "", err
+	}
+	selected := filepath.Base(versionPath)
+	switch module {
+	case "node":
+		err = nodesvc.NewService(log, installDir).Use(selected, symlinkPath)
+	default:
+		err = javasvc.NewService(log, installDir).Use(selected, symlinkPath)
+	}
+	if err != nil {
+		return "", err
+	}
+	recordManualOrigin(module, selected)
+
+	return fmt.Sprintf("%s: activated %s", module, strings.TrimPrefix(selected, "v")), nil
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	nodesvc "aem/internal/node"
+	"aem/pkg/errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newNodeCmd() *cobra.Command {
+	nodeCmd := &cobra.Command{
+		Use:   "node",
+		Short: "Node.js specific commands",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	nodeCmd.AddCommand(newReinstallPackagesFromCmd())
+
+	return nodeCmd
+}
+
+type reinstalledPackages struct {
+	From     string   `json:"from" yaml:"from"`
+	To       string   `json:"to" yaml:"to"`
+	Packages []string `json:"packages" yaml:"packages"`
+}
+
+func newReinstallPackagesFromCmd() *cobra.Command {
+	var to string
+
+	reinstallCmd := &cobra.Command{
+		Use:   "reinstall-packages-from [version]",
+		Short: "Install the global npm packages of another Node version into the active one",
+		Long: "Install the packages installed globally into the given Node version, except\n" +
+			"npm and corepack, into the active version or the one given with --to. The\n" +
+			"newest release of each package is installed.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			installDir, err := fs.GetInstallDir()
+			if err != nil {
+				return err
+			}
+			service := nodesvc.NewService(log, installDir)
+
+			if to == "" {
+				if to, err = service.GetCurrentNodeVersion(); err != nil {
+					return err
+				}
+				if to == "" {
+					return errors.NewValidationError("no active Node.js version, select one with 'aem use node <version>' or pass --to")
+				}
+			}
+			if name, ok := fs.ExternalName(filepath.Join(installDir, "node"), to); ok {
+				return errors.NewValidationError(fmt.Sprintf("%s is a linked runtime, aem does not install packages into it", name))
+			}
+			if strings.TrimPrefix(to, "v") == strings.TrimPrefix(args[0], "v") {
+				return errors.NewValidationError("source and target Node.js versions are the same: " + to)
+			}
+
+			packages, err := service.GlobalPackages(args[0])
+			if err != nil {
+				return err
+			}
+			result := reinstalledPackages{From: args[0], To: to, Packages: append([]string{}, packages...)}
+			if len(packages) > 0 {
+				if err := service.InstallGlobalPackages(to, packages); err != nil {
+					return err
+				}
+				if err := reshimIfEnabled(); err != nil {
+					return err
+				}
+			}
+			return render(result, func() {
+				if len(result.Packages) == 0 {
+					fmt.Printf("Node.js %s has no global packages\n", result.From)
+					return
+				}
+				fmt.Printf("Reinstalled %s from node %s into node %s\n", strings.Join(result.Packages, ", "), result.From, result.To)
+			})
+		},
+	}
+	reinstallCmd.Flags().StringVar(&to, "to", "", "Node version to install the packages into instead of the active one")
+
+	return reinstallCmd
+}
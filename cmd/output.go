@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"aem/pkg/errors"
+	"aem/pkg/progress"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+var outputFormat string
+
+const outputFlagUsage = "output format: table, json or yaml"
+
+type errorOutput struct {
+	Error errorDetail `json:"error" yaml:"error"`
+}
+
+type errorDetail struct {
+	Type    string `json:"type" yaml:"type"`
+	Message string `json:"message" yaml:"message"`
+	Cause   string `json:"cause,omitempty" yaml:"cause,omitempty"`
+}
+
+func validateOutputFormat() error {
+	switch outputFormat {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	default:
+		return errors.NewValidationError(fmt.Sprintf("unsupported output format %q (expected table, json or yaml)", outputFormat))
+	}
+}
+
+// applyOutputFormat validates the selected format and, for machine-readable
+// output, keeps logs, progress bars and cobra's error messages off stdout.
+func applyOutputFormat(cmd *cobra.Command) error {
+	if err := validateOutputFormat(); err != nil {
+		return err
+	}
+	if machineOutput() {
+		log.SetOutput(os.Stderr)
+		progress.SetOutput(os.Stderr)
+		cmd.Root().SilenceErrors = true
+		cmd.Root().SilenceUsage = true
+	}
+	return nil
+}
+
+func machineOutput() bool {
+	return outputFormat == outputJSON || outputFormat == outputYAML
+}
+
+// render writes v in the selected machine-readable format, or calls table
+// for the default human-readable output.
+func render(v interface{}, table func()) error {
+	switch outputFormat {
+	case outputJSON:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case outputYAML:
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+		return encoder.Close()
+	default:
+		table()
+	}
+	return nil
+}
+
+// renderError reports a failed command on stdout in the selected format, so
+// that scripts always receive parseable output.
+func renderError(err error) {
+	detail := errorDetail{Type: "ERROR", Message: err.Error()}
+
+	var aemErr *errors.AEMError
+	if stderrors.As(err, &aemErr) {
+		detail.Type = aemErr.Type
+		if err == error(aemErr) {
+			detail.Message = aemErr.Message
+			if aemErr.Cause != nil {
+				detail.Cause = aemErr.Cause.Error()
+			}
+		}
+	}
+
+	if renderErr := render(errorOutput{Error: detail}, func() {}); renderErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+}
@@ -2,15 +2,25 @@ package cmd
 
 import (
 	"aem/assets"
+	gradleext "aem/extensions/gradle"
 	javaext "aem/extensions/java"
 	nodeext "aem/extensions/node"
+	pythonext "aem/extensions/python"
+	rubyext "aem/extensions/ruby"
+	runtimesext "aem/extensions/runtimes"
+	"aem/internal/config"
+	gradlesvc "aem/internal/gradle"
 	javasvc "aem/internal/java"
+	"aem/internal/manager"
 	nodesvc "aem/internal/node"
+	pythonsvc "aem/internal/python"
+	rubysvc "aem/internal/ruby"
 	"aem/internal/setup"
-	"aem/internal/manager"
 	"aem/pkg/filesystem"
 	"aem/pkg/logger"
 	"aem/pkg/process"
+	"aem/pkg/settings"
+	"aem/pkg/state"
 	"context"
 	"fmt"
 	"os"
@@ -33,15 +43,20 @@ var extensionMgr = manager.NewExtensionManager()
 
 var rootCmd = &cobra.Command{
 	Use: "aem",
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		log = logger.New(debug)
 		fs = filesystem.New(log)
 
+		if err := applyOutputFormat(cmd); err != nil {
+			return err
+		}
+
 		if debug {
 			log.Debug("AEM verbose mode enabled")
 			log.Debug("Operating System: %s", runtime.GOOS)
 			log.Debug("Architecture: %s", runtime.GOARCH)
 		}
+		return nil
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Print(assets.Banner)
@@ -63,36 +78,45 @@ var listCmd = &cobra.Command{
 
 		extension, exists := extensionMgr.GetExtension(module)
 		if !exists {
-			return fmt.Errorf("%s module does not exist", module)
+			def, err := findDefinedRuntime(module)
+			if err != nil {
+				return err
+			}
+			extension = runtimesext.NewRuntimeExtension(def)
 		}
 
 		versions, err := extension.ListVersions(version)
 		if err != nil {
 			return err
 		}
+		if len(versions) > 10 {
+			versions = versions[:10]
+		}
 
-		if len(versions) == 0 {
-			fmt.Println("This version is not found.")
+		result := versionList{Module: module, Versions: versions}
+		if version != nil {
+			result.Query = *version
 		}
 
-		if len(versions) > 10 {
-			for _, v := range versions[:10] {
-				fmt.Println(v)
+		return render(result, func() {
+			if len(versions) == 0 {
+				fmt.Println("This version is not found.")
 			}
-		} else {
 			for _, v := range versions {
 				fmt.Println(v)
 			}
-		}
-
-		return nil
+		})
 	},
 }
 
 var installCmd = &cobra.Command{
 	Use:   "install [module] [version]",
-	Short: "Install a Java or Node version",
-	Args:  cobra.ExactArgs(2),
+	Short: "Install a Node, Java, Gradle, Ruby or Python version",
+	Long: "Install a Node, Java, Gradle, Ruby or Python version into sys_installed.\n\n" +
+		"Ruby has no official prebuilt downloads and no default mirror: set mirrors.ruby\n" +
+		"(or AEM_RUBY_MIRROR) to a server hosting relocatable builds next to an index.json\n" +
+		"before installing it, e.g. 'aem config set mirrors.ruby https://ruby.example.com'.",
+	Args: cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		module := args[0]
 		version := args[1]
@@ -109,18 +133,45 @@ var installCmd = &cobra.Command{
 			if err != nil {
 				return err
 			}
-			fmt.Printf("Installed node %s\n", installedVersion)
-			return nil
+			return reportInstalled("node", installedVersion)
 		case "java":
 			service := javasvc.NewService(log, installDir)
 			installedVersion, err := service.Install(version)
 			if err != nil {
 				return err
 			}
-			fmt.Printf("Installed java %s\n", strings.TrimPrefix(installedVersion, "v"))
-			return nil
+			return reportInstalled("java", installedVersion)
+		case "gradle":
+			service := gradlesvc.NewService(log, installDir)
+			installedVersion, err := service.Install(version)
+			if err != nil {
+				return err
+			}
+			return reportInstalled("gradle", installedVersion)
+		case "ruby":
+			service := rubysvc.NewService(log, installDir)
+			installedVersion, err := service.Install(version)
+			if err != nil {
+				return err
+			}
+			return reportInstalled("ruby", installedVersion)
+		case "python":
+			service := pythonsvc.NewService(log, installDir)
+			installedVersion, err := service.Install(version)
+			if err != nil {
+				return err
+			}
+			return reportInstalled("python", installedVersion)
 		default:
-			return fmt.Errorf("%s module does not exist", module)
+			service, err := definedRuntime(module, installDir)
+			if err != nil {
+				return err
+			}
+			installedVersion, err := service.Install(version)
+			if err != nil {
+				return err
+			}
+			return reportInstalled(module, installedVersion)
 		}
 	},
 }
@@ -128,7 +179,7 @@ var installCmd = &cobra.Command{
 var useCmd = &cobra.Command{
 	Use:     "use [module] [version]",
 	Aliases: []string{"set"},
-	Short:   "Switch the active Java or Node version",
+	Short:   "Switch the active Node, Java, Gradle, Ruby or Python version",
 	Args:    cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		module := args[0]
@@ -142,28 +193,73 @@ var useCmd = &cobra.Command{
 		switch module {
 		case "node":
 			service := nodesvc.NewService(log, installDir)
-			symlinkPath, err := resolveRuntimeSymlinkPath("AEM_NODE_SYMLINK", "node")
+			symlinkPath, err := resolveRuntimeSymlinkPath("node")
 			if err != nil {
 				return err
 			}
 			if err := service.Use(version, symlinkPath); err != nil {
 				return err
 			}
-			fmt.Printf("Using node %s\n", version)
-			return nil
+			recordManualOrigin("node", version)
+			return reportUsing("node", version, symlinkPath)
 		case "java":
 			service := javasvc.NewService(log, installDir)
-			symlinkPath, err := resolveRuntimeSymlinkPath("AEM_JAVA_SYMLINK", "java")
+			symlinkPath, err := resolveRuntimeSymlinkPath("java")
 			if err != nil {
 				return err
 			}
 			if err := service.Use(normalizeJavaVersion(version), symlinkPath); err != nil {
 				return err
 			}
-			fmt.Printf("Using java %s\n", strings.TrimPrefix(version, "v"))
-			return nil
+			recordManualOrigin("java", version)
+			return reportUsing("java", version, symlinkPath)
+		case "gradle":
+			service := gradlesvc.NewService(log, installDir)
+			symlinkPath, err := resolveRuntimeSymlinkPath("gradle")
+			if err != nil {
+				return err
+			}
+			if err := service.Use(version, symlinkPath); err != nil {
+				return err
+			}
+			recordManualOrigin("gradle", version)
+			return reportUsing("gradle", version, symlinkPath)
+		case "ruby":
+			service := rubysvc.NewService(log, installDir)
+			symlinkPath, err := resolveRuntimeSymlinkPath("ruby")
+			if err != nil {
+				return err
+			}
+			if err := service.Use(version, symlinkPath); err != nil {
+				return err
+			}
+			recordManualOrigin("ruby", version)
+			return reportUsing("ruby", version, symlinkPath)
+		case "python":
+			service := pythonsvc.NewService(log, installDir)
+			symlinkPath, err := resolveRuntimeSymlinkPath("python")
+			if err != nil {
+				return err
+			}
+			if err := service.Use(version, symlinkPath); err != nil {
+				return err
+			}
+			recordManualOrigin("python", version)
+			return reportUsing("python", version, symlinkPath)
 		default:
-			return fmt.Errorf("%s module does not exist", module)
+			service, err := definedRuntime(module, installDir)
+			if err != nil {
+				return err
+			}
+			symlinkPath, err := resolveRuntimeSymlinkPath(module)
+			if err != nil {
+				return err
+			}
+			if err := service.Use(version, symlinkPath); err != nil {
+				return err
+			}
+			recordManualOrigin(module, version)
+			return reportUsing(module, version, symlinkPath)
 		}
 	},
 }
@@ -187,76 +283,47 @@ var currentCmd = &cobra.Command{
 			return err
 		}
 
-		androidPath, err := st.CurrentAndroidPath()
+		gradleVersion, err := st.CurrentGradleVersion()
 		if err != nil {
 			return err
 		}
 
-		printCurrent("node", nodeVersion)
-		printCurrent("java", javaVersion)
-		if androidPath == "" {
-			fmt.Println("android: none")
-		} else {
-			fmt.Printf("android: %s\n", androidPath)
-		}
-
-		return nil
-	},
-}
-
-var doctorCmd = &cobra.Command{
-	Use:   "doctor",
-	Short: "Show local AEM state and health checks",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		aemHome, err := fs.GetAEMHome()
-		if err != nil {
-			return err
-		}
-		installDir, err := fs.GetInstallDir()
-		if err != nil {
-			return err
-		}
-		currentRoot, err := fs.GetCurrentRoot()
-		if err != nil {
-			return err
-		}
-		st, err := fs.GetState()
+		rubyVersion, err := st.CurrentRubyVersion()
 		if err != nil {
 			return err
 		}
 
-		nodeVersion, err := st.CurrentNodeVersion()
-		if err != nil {
-			return err
-		}
-		javaVersion, err := st.CurrentJavaVersion()
+		pythonVersion, err := st.CurrentPythonVersion()
 		if err != nil {
 			return err
 		}
+
 		androidPath, err := st.CurrentAndroidPath()
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("AEM home: %s\n", aemHome)
-		fmt.Printf("Install dir: %s\n", installDir)
-		fmt.Printf("Current links: %s\n", currentRoot)
-		fmt.Printf("Node installed: %d\n", countInstalledDirs(filepath.Join(installDir, "node")))
-		fmt.Printf("Java installed: %d\n", countInstalledDirs(filepath.Join(installDir, "java")))
-		printDoctorRuntime("node", nodeVersion, filepath.Join(currentRoot, "node"))
-		printDoctorRuntime("java", javaVersion, filepath.Join(currentRoot, "java"))
-		if androidPath == "" {
-			fmt.Printf("android current: missing (%s)\n", filepath.Join(currentRoot, "android"))
-		} else {
-			fmt.Printf("android current: %s\n", androidPath)
+		runtimes := []currentRuntime{
+			newCurrentRuntime(st, "node", nodeVersion),
+			newCurrentRuntime(st, "java", javaVersion),
+			newCurrentRuntime(st, "gradle", gradleVersion),
+			newCurrentRuntime(st, "ruby", rubyVersion),
+			newCurrentRuntime(st, "python", pythonVersion),
 		}
-		if legacyVersionsExists(aemHome) {
-			fmt.Printf("versions.json: present (%s)\n", filepath.Join(aemHome, "versions.json"))
-		} else {
-			fmt.Println("versions.json: absent")
+		for _, def := range definedRuntimes() {
+			version, err := st.CurrentVersion(def.Name)
+			if err != nil {
+				return err
+			}
+			runtimes = append(runtimes, newCurrentRuntime(st, def.Name, version))
 		}
+		runtimes = append(runtimes, currentRuntime{Module: "android", Path: androidPath})
 
-		return nil
+		return render(currentRuntimes{Runtimes: runtimes}, func() {
+			for _, current := range runtimes {
+				printCurrent(current)
+			}
+		})
 	},
 }
 
@@ -269,17 +336,40 @@ func Execute() {
 	javaExtension := javaext.NewJavaExtension()
 	extensionMgr.RegisterExtension("node", nodeExtension)
 	extensionMgr.RegisterExtension("java", javaExtension)
+	extensionMgr.RegisterExtension("gradle", gradleext.NewGradleExtension())
+	extensionMgr.RegisterExtension("ruby", rubyext.NewRubyExtension())
+	extensionMgr.RegisterExtension("python", pythonext.NewPythonExtension())
 
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "enable verbose mode")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputTable, outputFlagUsage)
 
 	rootCmd.AddCommand(newSetupCmd())
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(installCmd)
 	rootCmd.AddCommand(useCmd)
 	rootCmd.AddCommand(currentCmd)
-	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(newDefaultCmd())
+	rootCmd.AddCommand(newDoctorCmd())
+	rootCmd.AddCommand(newConfigCmd())
+	rootCmd.AddCommand(newEnvCmd())
+	rootCmd.AddCommand(newExecCmd())
+	rootCmd.AddCommand(newShellCmd())
+	rootCmd.AddCommand(newReshimCmd())
+	rootCmd.AddCommand(newImportCmd())
+	rootCmd.AddCommand(newLinkCmd())
+	rootCmd.AddCommand(newGCCmd())
+	rootCmd.AddCommand(newDuCmd())
+	rootCmd.AddCommand(newOutdatedCmd())
+	rootCmd.AddCommand(newUpgradeCmd())
+	rootCmd.AddCommand(newNodeCmd())
+	rootCmd.AddCommand(newMigrateCmd())
+	rootCmd.AddCommand(newShimExecCmd())
 
 	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		if machineOutput() {
+			renderError(err)
+			os.Exit(1)
+		}
 		if log != nil {
 			log.Fatal("Command execution failed: %v", err)
 		} else {
@@ -289,51 +379,135 @@ func Execute() {
 	}
 }
 
-func printCurrent(name, version string) {
-	if version == "" {
-		fmt.Printf("%s: none\n", name)
-		return
-	}
-	fmt.Printf("%s: %s\n", name, version)
+type versionList struct {
+	Module   string   `json:"module" yaml:"module"`
+	Query    string   `json:"query,omitempty" yaml:"query,omitempty"`
+	Versions []string `json:"versions" yaml:"versions"`
+}
+
+type currentRuntimes struct {
+	Runtimes []currentRuntime `json:"runtimes" yaml:"runtimes"`
+}
+
+type currentRuntime struct {
+	Module         string `json:"module" yaml:"module"`
+	Version        string `json:"version,omitempty" yaml:"version,omitempty"`
+	Path           string `json:"path,omitempty" yaml:"path,omitempty"`
+	Source         string `json:"source,omitempty" yaml:"source,omitempty"`
+	Config         string `json:"config,omitempty" yaml:"config,omitempty"`
+	PackageManager string `json:"packageManager,omitempty" yaml:"packageManager,omitempty"`
 }
 
-func printDoctorRuntime(name, version, linkPath string) {
+func newCurrentRuntime(st *state.State, module, version string) currentRuntime {
+	current := currentRuntime{Module: module, Version: version}
 	if version == "" {
-		fmt.Printf("%s current: missing (%s)\n", name, linkPath)
-		return
+		return current
 	}
-	fmt.Printf("%s current: %s\n", name, version)
-}
 
-func countInstalledDirs(path string) int {
-	entries, err := fs.ListDir(path)
+	if linkPath, err := resolveRuntimeSymlinkPath(module); err == nil {
+		current.Path = linkPath
+		if module == "node" {
+			current.PackageManager = nodesvc.InstalledPackageManager(linkPath)
+		}
+	}
+
+	origin, err := st.Origin(module)
 	if err != nil {
-		return 0
+		log.Debug("Failed to read %s origin: %v", module, err)
 	}
+	current.Source = origin.Source
+	current.Config = origin.Config
+	return current
+}
 
-	total := 0
-	for _, entry := range entries {
-		if entry.IsDir() {
-			total++
+func printCurrent(current currentRuntime) {
+	if current.Module == "android" {
+		if current.Path == "" {
+			fmt.Println("android: none")
+			return
 		}
+		fmt.Printf("android: %s\n", current.Path)
+		return
+	}
+
+	if current.Version == "" {
+		fmt.Printf("%s: none\n", current.Module)
+		return
+	}
+
+	version := current.Version
+	if current.PackageManager != "" {
+		// Leave out the "+sha512.…" integrity suffix
+		version += " with " + strings.SplitN(current.PackageManager, "+", 2)[0]
+	}
+
+	switch current.Source {
+	case state.OriginProject:
+		fmt.Printf("%s: %s (project: %s)\n", current.Module, version, current.Config)
+	case state.OriginDefault:
+		fmt.Printf("%s: %s (default)\n", current.Module, version)
+	case state.OriginManual:
+		fmt.Printf("%s: %s (aem use)\n", current.Module, version)
+	default:
+		fmt.Printf("%s: %s\n", current.Module, version)
 	}
-	return total
 }
 
-func legacyVersionsExists(aemHome string) bool {
-	return fs.Exists(filepath.Join(aemHome, "versions.json"))
+type installResult struct {
+	Module  string `json:"module" yaml:"module"`
+	Version string `json:"version" yaml:"version"`
 }
 
-func resolveRuntimeSymlinkPath(envName, module string) (string, error) {
-	if value := os.Getenv(envName); value != "" {
-		return value, nil
+type useResult struct {
+	Module  string `json:"module" yaml:"module"`
+	Version string `json:"version" yaml:"version"`
+	Path    string `json:"path" yaml:"path"`
+}
+
+func reportInstalled(module, version string) error {
+	if err := reshimIfEnabled(); err != nil {
+		return err
 	}
+	result := installResult{Module: module, Version: strings.TrimPrefix(version, "v")}
+	return render(result, func() {
+		fmt.Printf("Installed %s %s\n", result.Module, result.Version)
+	})
+}
 
-	currentRoot, err := fs.GetCurrentRoot()
+func reportUsing(module, version, symlinkPath string) error {
+	result := useResult{Module: module, Version: strings.TrimPrefix(version, "v"), Path: symlinkPath}
+	return render(result, func() {
+		fmt.Printf("Using %s %s\n", result.Module, result.Version)
+	})
+}
+
+func recordManualOrigin(module, version string) {
+	st, err := fs.GetState()
+	if err == nil {
+		err = st.SetOrigin(module, state.Origin{Source: state.OriginManual, Version: version})
+		if err == nil {
+			err = st.RecordUsage(module, version, "")
+		}
+	}
+	if err != nil {
+		log.Debug("Failed to record %s origin: %v", module, err)
+	}
+}
+
+func resolveRuntimeSymlinkPath(module string) (string, error) {
+	cfg, err := settings.Load()
 	if err != nil {
 		return "", err
 	}
+	if linkPath := cfg.SymlinkPath(module); linkPath != "" {
+		return linkPath, nil
+	}
 
+	// Runtimes defined in AEM_HOME/runtimes always link from current/<name>
+	currentRoot, err := fs.GetCurrentRoot()
+	if err != nil {
+		return "", err
+	}
 	return filepath.Join(currentRoot, module), nil
 }
 
@@ -345,7 +519,9 @@ func normalizeJavaVersion(version string) string {
 }
 
 func newSetupCmd() *cobra.Command {
-	return &cobra.Command{
+	var profile string
+
+	setupCmd := &cobra.Command{
 		Use:   "setup",
 		Short: "Setup development environment from the nearest aem.json",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -355,9 +531,20 @@ func newSetupCmd() *cobra.Command {
 			}
 
 			setupService := setup.NewService(log, installDir)
-			return setupService.Setup()
+			result, err := setupService.Setup(config.ResolveProfile(profile))
+			if err != nil {
+				return err
+			}
+			if err := reshimIfEnabled(); err != nil {
+				return err
+			}
+			return render(result, func() {})
 		},
 	}
+
+	setupCmd.Flags().StringVarP(&profile, "profile", "p", "", "apply a profile from aem.json (defaults to $AEM_PROFILE)")
+
+	return setupCmd
 }
 
 // func newJavaCmd() *cobra.Command {
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"aem/internal/runtimes"
+	"fmt"
+)
+
+// definedRuntimes returns the runtimes defined in AEM_HOME/runtimes. A broken
+// definition is logged rather than failing commands that do not need it.
+func definedRuntimes() []*runtimes.Definition {
+	aemHome, err := fs.GetAEMHome()
+	if err != nil {
+		log.Debug("No runtime definitions loaded: %v", err)
+		return nil
+	}
+	defs, err := runtimes.Load(aemHome)
+	if err != nil {
+		log.Error("Ignoring runtime definitions: %v", err)
+		return nil
+	}
+	return defs
+}
+
+// findDefinedRuntime returns the definition of the runtime module defined in
+// AEM_HOME/runtimes.
+func findDefinedRuntime(module string) (*runtimes.Definition, error) {
+	aemHome, err := fs.GetAEMHome()
+	if err != nil {
+		return nil, err
+	}
+	def, ok, err := runtimes.Find(aemHome, module)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s module does not exist", module)
+	}
+	return def, nil
+}
+
+// definedRuntime returns the service of the runtime module defined in
+// AEM_HOME/runtimes.
+func definedRuntime(module, installDir string) (*runtimes.Service, error) {
+	def, err := findDefinedRuntime(module)
+	if err != nil {
+		return nil, err
+	}
+	return runtimes.NewService(log, installDir, def), nil
+}
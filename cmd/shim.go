@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"aem/internal/environment"
+	"aem/internal/shim"
+	"aem/pkg/settings"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+type reshimResult struct {
+	Dir   string   `json:"dir" yaml:"dir"`
+	Shims []string `json:"shims" yaml:"shims"`
+}
+
+func newReshimCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "reshim",
+		Short: "Regenerate the shims in AEM_HOME/shims for all installed runtimes",
+		Long: "Regenerate the shims in AEM_HOME/shims. Each shim picks the runtime version per\n" +
+			"invocation from the nearest project config, falling back to the default version\n" +
+			"and then the current link. Add AEM_HOME/shims to PATH to use them.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			installDir, err := fs.GetInstallDir()
+			if err != nil {
+				return err
+			}
+
+			service := shim.NewService(log, installDir)
+			tools, err := service.Reshim()
+			if err != nil {
+				return err
+			}
+			shimDir, err := service.Dir()
+			if err != nil {
+				return err
+			}
+			result := reshimResult{Dir: shimDir, Shims: append([]string{}, tools...)}
+			return render(result, func() {
+				fmt.Printf("Generated %d shims in %s\n", len(result.Shims), result.Dir)
+			})
+		},
+	}
+}
+
+// newShimExecCmd is what every shim invokes; it is not meant to be run directly.
+func newShimExecCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:                "shim-exec [module] [tool] [args...]",
+		Hidden:             true,
+		DisableFlagParsing: true,
+		SilenceUsage:       true,
+		Args:               cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			module, tool := args[0], args[1]
+
+			installDir, err := fs.GetInstallDir()
+			if err != nil {
+				return err
+			}
+
+			opts, err := shim.NewService(log, installDir).Resolve(module)
+			if err != nil {
+				return err
+			}
+			binary, err := shim.Binary(opts, tool)
+			if err != nil {
+				return err
+			}
+
+			vars, err := environment.Variables(opts, os.Environ())
+			if err != nil {
+				return err
+			}
+			for _, v := range vars {
+				if err := os.Setenv(v.Name, v.Value); err != nil {
+					return err
+				}
+			}
+			return runAttached(binary, args[2:]...)
+		},
+	}
+}
+
+// reshimIfEnabled keeps the shims in sync after an install in shim mode.
+func reshimIfEnabled() error {
+	cfg, err := settings.Load()
+	if err != nil {
+		return err
+	}
+	if !cfg.ShimsEnabled() {
+		return nil
+	}
+
+	installDir, err := fs.GetInstallDir()
+	if err != nil {
+		return err
+	}
+	if _, err := shim.NewService(log, installDir).Reshim(); err != nil {
+		return fmt.Errorf("failed to regenerate shims: %w", err)
+	}
+	return nil
+}
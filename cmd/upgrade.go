@@ -0,0 +1,444 @@
+package cmd
+
+import (
+	"aem/internal/config"
+	gradlesvc "aem/internal/gradle"
+	javasvc "aem/internal/java"
+	nodesvc "aem/internal/node"
+	pythonsvc "aem/internal/python"
+	rubysvc "aem/internal/ruby"
+	"aem/pkg/errors"
+	"aem/pkg/settings"
+	"aem/pkg/state"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/semver"
+)
+
+var upgradeModules = []string{"node", "java", "gradle", "ruby", "python"}
+
+// upgradeFields maps each upgradable module to its project config field.
+var upgradeFields = map[string]string{
+	"node":   "node",
+	"java":   "jdk",
+	"gradle": "gradle",
+	"ruby":   "ruby",
+	"python": "python",
+}
+
+// resolver is implemented by the runtime services.
+type resolver interface {
+	Resolve(version string) (string, error)
+	LatestLTS() (string, error)
+	Install(version string) (string, error)
+	Use(version string, symlinkPath string) error
+}
+
+type outdatedRuntime struct {
+	Module    string `json:"module" yaml:"module"`
+	Spec      string `json:"spec" yaml:"spec"`
+	Active    string `json:"active" yaml:"active"`
+	Latest    string `json:"latest,omitempty" yaml:"latest,omitempty"`
+	LatestLTS string `json:"latestLTS,omitempty" yaml:"latestLTS,omitempty"`
+	Outdated  bool   `json:"outdated" yaml:"outdated"`
+	External  bool   `json:"external,omitempty" yaml:"external,omitempty"`
+}
+
+const (
+	upgradeUpgraded = "upgraded"
+	upgradeSwitched = "switched"
+	upgradeCurrent  = "current"
+	upgradeLinked   = "linked"
+)
+
+type upgradedRuntime struct {
+	Module string `json:"module" yaml:"module"`
+	Spec   string `json:"spec" yaml:"spec"`
+	Status string `json:"status" yaml:"status"`
+	From   string `json:"from,omitempty" yaml:"from,omitempty"`
+	To     string `json:"to,omitempty" yaml:"to,omitempty"`
+	// PinnedIn is the config --write recorded the new version in.
+	PinnedIn string `json:"pinnedIn,omitempty" yaml:"pinnedIn,omitempty"`
+	// LockedIn is the lock file --lock recorded the new version in.
+	LockedIn string `json:"lockedIn,omitempty" yaml:"lockedIn,omitempty"`
+	Field    string `json:"field,omitempty" yaml:"field,omitempty"`
+}
+
+type upgradeReport struct {
+	Config   string            `json:"config,omitempty" yaml:"config,omitempty"`
+	Runtimes []upgradedRuntime `json:"runtimes" yaml:"runtimes"`
+}
+
+type outdatedReport struct {
+	Config   string            `json:"config,omitempty" yaml:"config,omitempty"`
+	Runtimes []outdatedRuntime `json:"runtimes" yaml:"runtimes"`
+}
+
+// upgradeScope is the set of version specs outdated and upgrade work on:
+// the nearest project's, or the defaults and active versions.
+type upgradeScope struct {
+	resolved   *config.ResolvedConfig
+	installDir string
+	state      *state.State
+	settings   *settings.Settings
+	specs      map[string]string
+}
+
+func newOutdatedCmd() *cobra.Command {
+	var global bool
+
+	outdatedCmd := &cobra.Command{
+		Use:   "outdated",
+		Short: "Show active runtime versions that have newer releases",
+		Long: "Compare the active Node, Java, Gradle, Ruby and Python versions with the newest release\n" +
+			"matching the version spec of the nearest aem.json, or of the defaults and active\n" +
+			"versions outside a project or with --global, and with the newest LTS release.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			scope, err := loadUpgradeScope(global)
+			if err != nil {
+				return err
+			}
+
+			report := outdatedReport{Runtimes: []outdatedRuntime{}}
+			if scope.resolved != nil {
+				report.Config = scope.resolved.Path()
+			}
+			for _, module := range upgradeModules {
+				spec, ok := scope.specs[module]
+				if !ok {
+					continue
+				}
+				runtime, err := scope.outdated(module, spec)
+				if err != nil {
+					return err
+				}
+				report.Runtimes = append(report.Runtimes, runtime)
+			}
+
+			return render(report, func() {
+				printOutdated(report)
+			})
+		},
+	}
+	outdatedCmd.Flags().BoolVar(&global, "global", false, "check the defaults and active versions instead of the nearest aem.json")
+
+	return outdatedCmd
+}
+
+func newUpgradeCmd() *cobra.Command {
+	var (
+		global bool
+		lts    bool
+		write  bool
+		lock   bool
+	)
+
+	upgradeCmd := &cobra.Command{
+		Use:   "upgrade [module]",
+		Short: "Install and switch to the newest release matching the version spec",
+		Long: "Install the newest release matching the version spec of the nearest aem.json,\n" +
+			"or of the defaults and active versions, and switch to it. --lts moves to the\n" +
+			"newest LTS release instead. --write pins the new version in aem.json; --lock\n" +
+			"records it in the aem.lock next to aem.json instead, which 'aem setup' installs\n" +
+			"while it still matches the version spec.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			scope, err := loadUpgradeScope(global)
+			if err != nil {
+				return err
+			}
+			if (write || lock) && scope.resolved == nil {
+				return errors.NewValidationError("--write and --lock need a project config, run them next to an aem.json")
+			}
+
+			modules := upgradeModules
+			if len(args) == 1 {
+				module, ok := defaultModules[args[0]]
+				if !ok {
+					return fmt.Errorf("%s module does not exist", args[0])
+				}
+				if _, ok := scope.specs[module]; !ok {
+					return errors.NewValidationError(fmt.Sprintf("no %s version to upgrade: it is neither configured nor active", module))
+				}
+				modules = []string{module}
+			}
+
+			report := upgradeReport{Runtimes: []upgradedRuntime{}}
+			if scope.resolved != nil {
+				report.Config = scope.resolved.Path()
+			}
+			for _, module := range modules {
+				spec, ok := scope.specs[module]
+				if !ok {
+					continue
+				}
+				runtime, err := scope.upgrade(module, spec, lts, write, lock)
+				if err != nil {
+					return err
+				}
+				report.Runtimes = append(report.Runtimes, runtime)
+			}
+			if err := reshimIfEnabled(); err != nil {
+				return err
+			}
+
+			return render(report, func() {
+				for _, runtime := range report.Runtimes {
+					printUpgraded(runtime, report.Config, lts)
+				}
+			})
+		},
+	}
+	upgradeCmd.Flags().BoolVar(&global, "global", false, "upgrade the defaults and active versions instead of the nearest aem.json")
+	upgradeCmd.Flags().BoolVar(&lts, "lts", false, "upgrade to the newest LTS release instead of the newest matching one")
+	upgradeCmd.Flags().BoolVar(&write, "write", false, "pin the upgraded version in the project config")
+	upgradeCmd.Flags().BoolVar(&lock, "lock", false, "record the upgraded version in aem.lock next to the project config")
+
+	return upgradeCmd
+}
+
+func loadUpgradeScope(global bool) (*upgradeScope, error) {
+	installDir, err := fs.GetInstallDir()
+	if err != nil {
+		return nil, err
+	}
+	st, err := fs.GetState()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := settings.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	scope := &upgradeScope{installDir: installDir, state: st, settings: cfg, specs: make(map[string]string)}
+	if !global {
+		if _, err := config.FindProjectConfig(""); err == nil {
+			if scope.resolved, err = config.ResolveProjectConfig(""); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if scope.resolved != nil {
+		if scope.resolved.Config.Node != "" {
+			scope.specs["node"] = scope.resolved.Config.Node
+		}
+		if scope.resolved.Config.JDK != "" {
+			scope.specs["java"] = scope.resolved.Config.JDK
+		}
+		if scope.resolved.Config.Gradle != "" {
+			scope.specs["gradle"] = scope.resolved.Config.Gradle
+		}
+		if scope.resolved.Config.Ruby != "" {
+			scope.specs["ruby"] = scope.resolved.Config.Ruby
+		}
+		if scope.resolved.Config.Python != "" {
+			scope.specs["python"] = scope.resolved.Config.Python
+		}
+		return scope, nil
+	}
+
+	for _, module := range upgradeModules {
+		if spec := cfg.DefaultVersion(module); spec != "" {
+			scope.specs[module] = spec
+			continue
+		}
+		active, err := st.CurrentVersion(module)
+		if err != nil {
+			return nil, err
+		}
+		if active != "" {
+			// Without a default, stay on the major version that is active
+			scope.specs[module] = strings.SplitN(active, ".", 2)[0]
+			if _, external := fs.ExternalName(filepath.Join(installDir, module), active); external {
+				scope.specs[module] = active
+			}
+		}
+	}
+	return scope, nil
+}
+
+func (s *upgradeScope) service(module string) resolver {
+	switch module {
+	case "node":
+		return nodesvc.NewService(log, s.installDir)
+	case "gradle":
+		return gradlesvc.NewService(log, s.installDir)
+	case "ruby":
+		return rubysvc.NewService(log, s.installDir)
+	case "python":
+		return pythonsvc.NewService(log, s.installDir)
+	default:
+		return javasvc.NewService(log, s.installDir)
+	}
+}
+
+func (s *upgradeScope) outdated(module, spec string) (outdatedRuntime, error) {
+	active, err := s.state.CurrentVersion(module)
+	if err != nil {
+		return outdatedRuntime{}, err
+	}
+	runtime := outdatedRuntime{Module: module, Spec: spec, Active: active}
+	if _, ok := fs.ExternalName(filepath.Join(s.installDir, module), spec); ok {
+		runtime.External = true
+		return runtime, nil
+	}
+
+	service := s.service(module)
+	latest, err := service.Resolve(spec)
+	if err != nil {
+		return runtime, err
+	}
+	lts, err := service.LatestLTS()
+	if err != nil {
+		return runtime, err
+	}
+
+	runtime.Latest = strings.TrimPrefix(latest, "v")
+	runtime.LatestLTS = strings.TrimPrefix(lts, "v")
+	runtime.Outdated = active != runtime.Latest
+	return runtime, nil
+}
+
+func (s *upgradeScope) upgrade(module, spec string, lts, write, lock bool) (upgradedRuntime, error) {
+	runtime := upgradedRuntime{Module: module, Spec: spec}
+	if _, ok := fs.ExternalName(filepath.Join(s.installDir, module), spec); ok {
+		runtime.Status = upgradeLinked
+		return runtime, nil
+	}
+
+	service := s.service(module)
+	var (
+		target string
+		err    error
+	)
+	if lts {
+		target, err = service.LatestLTS()
+	} else {
+		target, err = service.Resolve(spec)
+	}
+	if err != nil {
+		return runtime, err
+	}
+	target = strings.TrimPrefix(target, "v")
+
+	active, err := s.state.CurrentVersion(module)
+	if err != nil {
+		return runtime, err
+	}
+	runtime.From, runtime.To = active, target
+	if active == target {
+		runtime.Status = upgradeCurrent
+	} else {
+		installed, err := service.Install(target)
+		if err != nil {
+			return runtime, err
+		}
+		if err := service.Use(installed, s.settings.SymlinkPath(module)); err != nil {
+			return runtime, err
+		}
+		s.recordUpgrade(module, installed)
+
+		runtime.Status = upgradeUpgraded
+		if active != "" && semver.Compare("v"+target, "v"+active) < 0 {
+			runtime.Status = upgradeSwitched
+		}
+	}
+
+	field := upgradeFields[module]
+	if write {
+		configPath := s.resolved.Sources[field]
+		if configPath == "" {
+			configPath = s.resolved.Path()
+		}
+		if err := config.SetValue(configPath, field, target); err != nil {
+			return runtime, err
+		}
+		runtime.PinnedIn, runtime.Field = configPath, field
+	}
+	if lock {
+		lockPath := config.LockPath(s.resolved.Path())
+		if err := config.SetLocked(lockPath, field, target); err != nil {
+			return runtime, err
+		}
+		runtime.LockedIn, runtime.Field = lockPath, field
+	}
+	return runtime, nil
+}
+
+func printUpgraded(runtime upgradedRuntime, configPath string, lts bool) {
+	switch runtime.Status {
+	case upgradeLinked:
+		fmt.Printf("%s: %s is a linked runtime, skipping\n", runtime.Module, runtime.Spec)
+		return
+	case upgradeCurrent:
+		fmt.Printf("%s: %s is already the newest release\n", runtime.Module, runtime.To)
+	default:
+		from := runtime.From
+		if from == "" {
+			from = "none"
+		}
+		fmt.Printf("%s: %s %s -> %s\n", runtime.Module, runtime.Status, from, runtime.To)
+	}
+
+	if runtime.PinnedIn != "" {
+		fmt.Printf("%s: pinned %s to %s in %s\n", runtime.Module, runtime.Field, runtime.To, runtime.PinnedIn)
+	}
+	if runtime.LockedIn != "" {
+		fmt.Printf("%s: locked %s to %s in %s\n", runtime.Module, runtime.Field, runtime.To, runtime.LockedIn)
+	}
+	if runtime.PinnedIn == "" && lts && configPath != "" {
+		fmt.Printf("%s: %s still asks for %q, run with --write to keep the LTS release\n", runtime.Module, configPath, runtime.Spec)
+	}
+}
+
+// recordUpgrade records the switch like setup or use would, so current and
+// gc keep reporting the right origin and usage.
+func (s *upgradeScope) recordUpgrade(module, version string) {
+	origin := state.Origin{Source: state.OriginManual, Version: version}
+	if s.resolved != nil {
+		origin = state.Origin{Source: state.OriginProject, Version: version, Config: s.resolved.Path()}
+	} else if s.settings.DefaultVersion(module) != "" {
+		origin.Source = state.OriginDefault
+	}
+
+	err := s.state.SetOrigin(module, origin)
+	if err == nil {
+		err = s.state.RecordUsage(module, version, origin.Config)
+	}
+	if err != nil {
+		log.Debug("Failed to record %s origin: %v", module, err)
+	}
+}
+
+func printOutdated(report outdatedReport) {
+	if report.Config != "" {
+		fmt.Printf("Project: %s\n", report.Config)
+	}
+	if len(report.Runtimes) == 0 {
+		fmt.Println("No runtimes configured or active")
+		return
+	}
+
+	fmt.Printf("%-6s %-10s %-10s %-10s %-10s\n", "MODULE", "SPEC", "ACTIVE", "LATEST", "LTS")
+	for _, runtime := range report.Runtimes {
+		active := runtime.Active
+		if active == "" {
+			active = "none"
+		}
+		latest, lts := runtime.Latest, runtime.LatestLTS
+		if runtime.External {
+			latest, lts = "linked", "-"
+		}
+		fmt.Printf("%-6s %-10s %-10s %-10s %-10s", runtime.Module, runtime.Spec, active, latest, lts)
+		if runtime.Outdated {
+			fmt.Print("  upgrade available")
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,94 @@
+package gradle
+
+import (
+	gradlesvc "aem/internal/gradle"
+	"aem/internal/manager"
+	"aem/pkg/downloader"
+	"aem/pkg/settings"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type GradleExtension struct {
+	manager.BaseExtension
+	client *http.Client
+}
+
+func NewGradleExtension() *GradleExtension {
+	cfg, _ := settings.Load()
+	return &GradleExtension{
+		BaseExtension: manager.BaseExtension{BaseUrl: cfg.Mirror("gradle")},
+		client:        downloader.NewClient(cfg),
+	}
+}
+
+func (g *GradleExtension) CheckVersion(version string) (bool, error) {
+	releases, err := g.releases()
+	if err != nil {
+		return false, err
+	}
+
+	for _, release := range releases {
+		if release == "v"+strings.TrimPrefix(version, "v") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (g *GradleExtension) ListVersions(version *string) ([]string, error) {
+	releases, err := g.releases()
+	if err != nil {
+		return []string{}, err
+	}
+
+	// Newest first, like the other extensions
+	var versions []string
+	for i := len(releases) - 1; i >= 0; i-- {
+		release := strings.TrimPrefix(releases[i], "v")
+		if version == nil || release == *version || strings.HasPrefix(release, *version+".") {
+			versions = append(versions, release)
+		}
+		if len(versions) == 10 {
+			break
+		}
+	}
+	return versions, nil
+}
+
+func (g *GradleExtension) GetDownloadURL(version string) (string, error) {
+	exists, err := g.CheckVersion(version)
+	if err != nil {
+		return "", fmt.Errorf("failed to check version: %w", err)
+	}
+	if !exists {
+		return "", fmt.Errorf("version %s not found", version)
+	}
+	return fmt.Sprintf("%s/distributions/gradle-%s-bin.zip", strings.TrimSuffix(g.BaseUrl, "/"), strings.TrimPrefix(version, "v")), nil
+}
+
+func (g *GradleExtension) releases() ([]string, error) {
+	jsonURL := strings.TrimSuffix(g.BaseUrl, "/") + "/versions/all"
+	resp, err := g.client.Get(jsonURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JSON response: %w", err)
+	}
+
+	releases, err := gradlesvc.ParseReleases(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	return releases, nil
+}
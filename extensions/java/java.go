@@ -2,6 +2,8 @@ package java
 
 import (
 	"aem/internal/manager"
+	"aem/pkg/downloader"
+	"aem/pkg/settings"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,6 +14,7 @@ import (
 
 type JavaExtension struct {
 	manager.BaseExtension
+	client *http.Client
 }
 
 type JavaRelease struct {
@@ -20,14 +23,16 @@ type JavaRelease struct {
 }
 
 func NewJavaExtension() *JavaExtension {
+	cfg, _ := settings.Load()
 	return &JavaExtension{
-		BaseExtension: manager.BaseExtension{BaseUrl: "https://api.azul.com/metadata/v1/zulu/packages/"},
+		BaseExtension: manager.BaseExtension{BaseUrl: cfg.Mirror("java")},
+		client:        downloader.NewClient(cfg),
 	}
 }
 
 func (n *JavaExtension) CheckVersion(version string) (bool, error) {
 	jsonURL := fmt.Sprintf(strings.TrimSuffix(n.BaseUrl, "/")+"?archive_type=zip&arch=%s&os=%s&java_package_type=jdk&page_size=1000&availability_type=CA&java_version=%s&javafx_bundled=false", "x64", "win", version)
-	resp, err := http.Get(jsonURL)
+	resp, err := n.client.Get(jsonURL)
 
 	if err != nil {
 		return false, err
@@ -68,7 +73,7 @@ func (n *JavaExtension) ListVersions(version *string) ([]string, error) {
 		jsonURL += fmt.Sprintf("&java_version=%s", *version)
 	}
 
-	resp, err := http.Get(jsonURL)
+	resp, err := n.client.Get(jsonURL)
 	if err != nil {
 		return []string{}, err
 	}
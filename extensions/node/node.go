@@ -2,6 +2,8 @@ package node
 
 import (
 	"aem/internal/manager"
+	"aem/pkg/downloader"
+	"aem/pkg/settings"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,6 +13,7 @@ import (
 
 type NodeExtension struct {
 	manager.BaseExtension
+	client *http.Client
 }
 
 type NodeJSRelease struct {
@@ -20,8 +23,10 @@ type NodeJSRelease struct {
 }
 
 func NewNodeExtension() *NodeExtension {
+	cfg, _ := settings.Load()
 	return &NodeExtension{
-		BaseExtension: manager.BaseExtension{BaseUrl: "https://www.nodejs.org/dist"},
+		BaseExtension: manager.BaseExtension{BaseUrl: cfg.Mirror("node")},
+		client:        downloader.NewClient(cfg),
 	}
 }
 
@@ -32,7 +37,7 @@ func (n *NodeExtension) CheckVersion(version string) (bool, error) {
 	}
 
 	jsonURL := strings.TrimSuffix(n.BaseUrl, "/") + "/index.json"
-	resp, err := http.Get(jsonURL)
+	resp, err := n.client.Get(jsonURL)
 
 	if err != nil {
 		return false, err
@@ -69,7 +74,7 @@ func (n *NodeExtension) ListVersions(version *string) ([]string, error) {
 	}
 
 	jsonURL := strings.TrimSuffix(n.BaseUrl, "/") + "/index.json"
-	resp, err := http.Get(jsonURL)
+	resp, err := n.client.Get(jsonURL)
 	if err != nil {
 		return []string{}, err
 	}
@@ -118,7 +123,7 @@ func (n *NodeExtension) GetDownloadURL(version string) (string, error) {
 	}
 
 	jsonURL := strings.TrimSuffix(n.BaseUrl, "/")
-	resp, err := http.Get(jsonURL)
+	resp, err := n.client.Get(jsonURL)
 	if err == nil && resp.StatusCode == 200 {
 		defer resp.Body.Close()
 		body, err := io.ReadAll(resp.Body)
@@ -0,0 +1,105 @@
+package python
+
+import (
+	"aem/internal/manager"
+	"aem/internal/platform"
+	pythonsvc "aem/internal/python"
+	"aem/pkg/downloader"
+	"aem/pkg/settings"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type PythonExtension struct {
+	manager.BaseExtension
+	client *http.Client
+}
+
+func NewPythonExtension() *PythonExtension {
+	cfg, _ := settings.Load()
+	return &PythonExtension{
+		BaseExtension: manager.BaseExtension{BaseUrl: cfg.Mirror("python")},
+		client:        downloader.NewClient(cfg),
+	}
+}
+
+func (p *PythonExtension) CheckVersion(version string) (bool, error) {
+	releases, err := p.releases()
+	if err != nil {
+		return false, err
+	}
+
+	for _, release := range releases {
+		if release.Version == strings.TrimPrefix(version, "v") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (p *PythonExtension) ListVersions(version *string) ([]string, error) {
+	releases, err := p.releases()
+	if err != nil {
+		return []string{}, err
+	}
+
+	// Newest first, like the other extensions
+	var versions []string
+	for i := len(releases) - 1; i >= 0; i-- {
+		release := releases[i]
+		if version == nil || release.Version == *version || strings.HasPrefix(release.Version, *version+".") {
+			versions = append(versions, release.Version)
+		}
+		if len(versions) == 10 {
+			break
+		}
+	}
+	return versions, nil
+}
+
+func (p *PythonExtension) GetDownloadURL(version string) (string, error) {
+	releases, err := p.releases()
+	if err != nil {
+		return "", fmt.Errorf("failed to check version: %w", err)
+	}
+
+	for _, release := range releases {
+		if release.Version == strings.TrimPrefix(version, "v") {
+			return release.URL, nil
+		}
+	}
+	return "", fmt.Errorf("version %s not found", version)
+}
+
+// releases returns the versions built by the newest releases.
+func (p *PythonExtension) releases() ([]pythonsvc.Release, error) {
+	req, err := http.NewRequest(http.MethodGet, pythonsvc.ReleasesURL(p.BaseUrl, 1), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = pythonsvc.RequestHeader(p.BaseUrl)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JSON response: %w", err)
+	}
+
+	var githubReleases []pythonsvc.GitHubRelease
+	if err := json.Unmarshal(body, &githubReleases); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	return pythonsvc.ParseReleases(githubReleases, platform.GetInfo().GetPythonTarget()), nil
+}
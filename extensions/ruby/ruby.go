@@ -0,0 +1,103 @@
+package ruby
+
+import (
+	"aem/internal/manager"
+	"aem/internal/platform"
+	rubysvc "aem/internal/ruby"
+	"aem/internal/runtimes"
+	"aem/pkg/downloader"
+	"aem/pkg/settings"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type RubyExtension struct {
+	manager.BaseExtension
+	client *http.Client
+}
+
+func NewRubyExtension() *RubyExtension {
+	cfg, _ := settings.Load()
+	return &RubyExtension{
+		BaseExtension: manager.BaseExtension{BaseUrl: cfg.Mirror("ruby")},
+		client:        downloader.NewClient(cfg),
+	}
+}
+
+func (r *RubyExtension) CheckVersion(version string) (bool, error) {
+	releases, err := r.releases()
+	if err != nil {
+		return false, err
+	}
+
+	for _, release := range releases {
+		if release.Version == "v"+strings.TrimPrefix(version, "v") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *RubyExtension) ListVersions(version *string) ([]string, error) {
+	releases, err := r.releases()
+	if err != nil {
+		return []string{}, err
+	}
+
+	// Newest first, like the other extensions
+	var versions []string
+	for i := len(releases) - 1; i >= 0; i-- {
+		release := strings.TrimPrefix(releases[i].Version, "v")
+		if version == nil || release == *version || strings.HasPrefix(release, *version+".") {
+			versions = append(versions, release)
+		}
+		if len(versions) == 10 {
+			break
+		}
+	}
+	return versions, nil
+}
+
+func (r *RubyExtension) GetDownloadURL(version string) (string, error) {
+	releases, err := r.releases()
+	if err != nil {
+		return "", fmt.Errorf("failed to check version: %w", err)
+	}
+
+	for _, release := range releases {
+		if release.Version == "v"+strings.TrimPrefix(version, "v") {
+			return release.URL, nil
+		}
+	}
+	return "", fmt.Errorf("version %s not found", version)
+}
+
+// releases returns the releases with a build for this platform, oldest first.
+func (r *RubyExtension) releases() ([]runtimes.Release, error) {
+	if r.BaseUrl == "" {
+		return nil, fmt.Errorf("no Ruby index configured, run 'aem config set mirrors.ruby <url>'")
+	}
+
+	resp, err := r.client.Get(rubysvc.IndexURL(r.BaseUrl))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JSON response: %w", err)
+	}
+
+	releases, err := rubysvc.ParseIndex(body, platform.GetInfo().GetRubyTarget(), r.BaseUrl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	return releases, nil
+}
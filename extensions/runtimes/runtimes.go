@@ -0,0 +1,97 @@
+package runtimes
+
+import (
+	"aem/internal/manager"
+	"aem/internal/runtimes"
+	"aem/pkg/downloader"
+	"aem/pkg/settings"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// RuntimeExtension lists the versions of a runtime defined in
+// AEM_HOME/runtimes.
+type RuntimeExtension struct {
+	manager.BaseExtension
+	client *http.Client
+	def    *runtimes.Definition
+}
+
+func NewRuntimeExtension(def *runtimes.Definition) *RuntimeExtension {
+	cfg, _ := settings.Load()
+	return &RuntimeExtension{
+		BaseExtension: manager.BaseExtension{BaseUrl: def.Index},
+		client:        downloader.NewClient(cfg),
+		def:           def,
+	}
+}
+
+func (r *RuntimeExtension) CheckVersion(version string) (bool, error) {
+	releases, err := r.releases()
+	if err != nil {
+		return false, err
+	}
+
+	for _, release := range releases {
+		if release == "v"+strings.TrimPrefix(version, "v") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *RuntimeExtension) ListVersions(version *string) ([]string, error) {
+	releases, err := r.releases()
+	if err != nil {
+		return []string{}, err
+	}
+
+	// Newest first, like the other extensions
+	var versions []string
+	for i := len(releases) - 1; i >= 0; i-- {
+		release := strings.TrimPrefix(releases[i], "v")
+		if version == nil || release == *version || strings.HasPrefix(release, *version+".") {
+			versions = append(versions, release)
+		}
+		if len(versions) == 10 {
+			break
+		}
+	}
+	return versions, nil
+}
+
+func (r *RuntimeExtension) GetDownloadURL(version string) (string, error) {
+	exists, err := r.CheckVersion(version)
+	if err != nil {
+		return "", fmt.Errorf("failed to check version: %w", err)
+	}
+	if !exists {
+		return "", fmt.Errorf("version %s not found", version)
+	}
+	return r.def.DownloadURL(version), nil
+}
+
+func (r *RuntimeExtension) releases() ([]string, error) {
+	resp, err := r.client.Get(r.BaseUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	candidates, err := r.def.Versions(body)
+	if err != nil {
+		return nil, err
+	}
+	return runtimes.FinalReleases(candidates), nil
+}
@@ -8,6 +8,7 @@ import (
 	"aem/pkg/errors"
 	"aem/pkg/filesystem"
 	"aem/pkg/logger"
+	"aem/pkg/settings"
 	"encoding/xml"
 	"fmt"
 	"os"
@@ -17,7 +18,7 @@ import (
 	"strings"
 )
 
-const androidRepositoryURL = "https://dl.google.com/android/repository/repository2-1.xml"
+const androidRepositoryIndex = "repository2-1.xml"
 
 type Service struct {
 	logger     *logger.Logger
@@ -25,6 +26,7 @@ type Service struct {
 	fs         *filesystem.FileSystem
 	zipper     *archiver.ZipExtractor
 	installDir string
+	mirror     string
 }
 
 type repositoryXML struct {
@@ -73,12 +75,18 @@ type baseRevision struct {
 }
 
 func NewService(logger *logger.Logger, installDir string) *Service {
+	cfg, err := settings.Load()
+	if err != nil {
+		logger.Debug("Using default Android repository mirror: %v", err)
+	}
+
 	return &Service{
 		logger:     logger,
 		downloader: downloader.New(logger),
 		fs:         filesystem.New(logger),
 		zipper:     archiver.NewZipExtractor(logger),
 		installDir: installDir,
+		mirror:     cfg.Mirror("android"),
 	}
 }
 
@@ -89,6 +97,16 @@ func (s *Service) Setup(cfg config.AndroidConfig, javaHome string) error {
 		return nil
 	}
 
+	if sdk, ok := s.ExternalSDK(); ok {
+		return s.checkExternalPackages(sdk, requestedPackages)
+	}
+
+	unlock, err := s.fs.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	sdkRoot := s.sdkRoot()
 	if err := s.fs.EnsureDir(sdkRoot); err != nil {
 		return err
@@ -118,13 +136,66 @@ func (s *Service) Use(symlinkPath string) error {
 	return s.fs.CreateSymlink(symlinkPath, s.sdkRoot())
 }
 
+// LicensesAccepted reports whether the Android SDK licence has been accepted.
+func (s *Service) LicensesAccepted() bool {
+	return s.fs.Exists(filepath.Join(s.sdkRoot(), "licenses", "android-sdk-license"))
+}
+
+func (s *Service) AcceptLicenses(javaHome string) error {
+	if sdk, ok := s.ExternalSDK(); ok {
+		return errors.NewValidationError(fmt.Sprintf("the Android SDK at %s was imported from %s and aem does not modify it, accept its licences with its SDK Manager", sdk.Path, sdk.Source))
+	}
+
+	unlock, err := s.fs.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return s.acceptLicenses(s.sdkRoot(), javaHome)
+}
+
 func (s *Service) sdkRoot() string {
 	return filepath.Join(s.installDir, "android", "sdk")
 }
 
+// ExternalSDK returns the SDK registered by 'aem import' in place of the one
+// aem manages. Its packages belong to Android Studio and are never changed.
+func (s *Service) ExternalSDK() (filesystem.ExternalRuntime, bool) {
+	runtimes, err := s.fs.ExternalRuntimes(filepath.Join(s.installDir, "android"))
+	if err != nil {
+		s.logger.Debug("Ignoring external Android SDK: %v", err)
+		return filesystem.ExternalRuntime{}, false
+	}
+	sdk, ok := runtimes[filepath.Base(s.sdkRoot())]
+	return sdk, ok
+}
+
+// checkExternalPackages fails unless every package is already installed in
+// the external SDK, e.g. "platforms;android-34" in platforms/android-34.
+func (s *Service) checkExternalPackages(sdk filesystem.ExternalRuntime, packages []string) error {
+	var missing []string
+	for _, pkg := range packages {
+		if !s.fs.Exists(filepath.Join(sdk.Path, filepath.Join(strings.Split(pkg, ";")...))) {
+			missing = append(missing, pkg)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.NewValidationError(fmt.Sprintf("the Android SDK at %s was imported from %s and is missing %s; aem does not modify it, install them with its SDK Manager or run 'aem link --remove android sdk' to let aem manage its own SDK", sdk.Path, sdk.Source, strings.Join(missing, ", ")))
+	}
+
+	s.logger.Debug("Android SDK packages are ready in %s", sdk.Path)
+	return nil
+}
+
 func (s *Service) ensureCommandLineTools(sdkRoot string) error {
-	binPath := s.sdkManagerPath(sdkRoot)
-	if s.fs.Exists(binPath) {
+	targetDir := filepath.Join(sdkRoot, "cmdline-tools", "latest")
+	probe, err := filepath.Rel(targetDir, s.sdkManagerPath(sdkRoot))
+	if err != nil {
+		return errors.NewFileSystemError("failed to resolve sdkmanager path", err)
+	}
+	if s.fs.InstallComplete(targetDir, probe) {
+		s.fs.MarkInstallComplete(targetDir)
 		s.logger.Debug("Android command-line tools already installed")
 		return nil
 	}
@@ -134,21 +205,16 @@ func (s *Service) ensureCommandLineTools(sdkRoot string) error {
 		return err
 	}
 
-	tmpDir, err := s.fs.GetTempDir()
+	stagingDir, err := s.fs.NewStagingDir("android-cmdline-tools")
 	if err != nil {
 		return err
 	}
-
-	zipPath := filepath.Join(tmpDir, filepath.Base(archiveURL))
-	extractDir := filepath.Join(tmpDir, "android_cmdline_tools_extract")
-
 	defer func() {
-		_ = s.fs.RemoveAll(zipPath)
-		_ = s.fs.RemoveAll(extractDir)
+		_ = s.fs.RemoveAll(stagingDir)
 	}()
 
-	_ = s.fs.RemoveAll(zipPath)
-	_ = s.fs.RemoveAll(extractDir)
+	zipPath := filepath.Join(stagingDir, filepath.Base(archiveURL))
+	extractDir := filepath.Join(stagingDir, "extract")
 
 	if err := s.downloader.Download(archiveURL, zipPath); err != nil {
 		return err
@@ -163,21 +229,15 @@ func (s *Service) ensureCommandLineTools(sdkRoot string) error {
 		return err
 	}
 
-	targetDir := filepath.Join(sdkRoot, "cmdline-tools", "latest")
-	if err := s.fs.EnsureDir(filepath.Dir(targetDir)); err != nil {
-		return err
-	}
-
-	_ = s.fs.RemoveAll(targetDir)
-	if err := s.fs.Move(sourceDir, targetDir); err != nil {
+	if err := ensureExecutable(filepath.Join(sourceDir, "bin")); err != nil {
 		return err
 	}
 
-	return ensureExecutable(filepath.Join(targetDir, "bin"))
+	return s.fs.CommitInstall(sourceDir, targetDir)
 }
 
 func (s *Service) resolveCommandLineToolsURL() (string, error) {
-	body, err := s.downloader.GetHTML(androidRepositoryURL)
+	body, err := s.downloader.GetHTML(s.mirror + "/" + androidRepositoryIndex)
 	if err != nil {
 		return "", errors.NewAPIError("failed to fetch Android repository metadata", err)
 	}
@@ -207,7 +267,7 @@ func (s *Service) resolveCommandLineToolsURL() (string, error) {
 				continue
 			}
 			candidates = append(candidates, candidate{
-				url:      "https://dl.google.com/android/repository/" + archive.Complete.URL,
+				url:      s.mirror + "/" + archive.Complete.URL,
 				revision: pkg.Revision,
 			})
 		}
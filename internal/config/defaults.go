@@ -0,0 +1,19 @@
+package config
+
+import "aem/pkg/settings"
+
+// DefaultProjectConfig builds a config from the user-level default versions
+// set with "aem default". It returns false when no default is set.
+func DefaultProjectConfig(cfg *settings.Settings) (*ProjectConfig, bool) {
+	defaults := &ProjectConfig{
+		Node:   cfg.DefaultVersion("node"),
+		JDK:    cfg.DefaultVersion("java"),
+		Gradle: cfg.DefaultVersion("gradle"),
+		Ruby:   cfg.DefaultVersion("ruby"),
+		Python: cfg.DefaultVersion("python"),
+	}
+	if defaults.Node == "" && defaults.JDK == "" && defaults.Gradle == "" && defaults.Ruby == "" && defaults.Python == "" {
+		return nil, false
+	}
+	return defaults, true
+}
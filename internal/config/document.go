@@ -0,0 +1,218 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+type nodeKind int
+
+const (
+	objectNode nodeKind = iota
+	arrayNode
+	stringNode
+	numberNode
+	boolNode
+	nullNode
+)
+
+func (k nodeKind) String() string {
+	switch k {
+	case objectNode:
+		return "object"
+	case arrayNode:
+		return "array"
+	case stringNode:
+		return "string"
+	case numberNode:
+		return "number"
+	case boolNode:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+type Position struct {
+	Line   int
+	Column int
+}
+
+// node is a position-aware view of a config document used for validation
+// before the document is decoded into ProjectConfig.
+type node struct {
+	kind    nodeKind
+	pos     Position
+	members []member
+	items   []*node
+	value   string
+	// float marks TOML floats, whose text is lost in decoding ("18.20"
+	// reads back as 18.2), so they are never coerced into strings.
+	float bool
+}
+
+type member struct {
+	key   string
+	pos   Position
+	value *node
+}
+
+// PositionError is a syntax error with the line and column where parsing stopped.
+type PositionError struct {
+	Position
+	Message string
+}
+
+func (e *PositionError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+type jsonParser struct {
+	data       []byte
+	lineStarts []int
+	dec        *json.Decoder
+}
+
+func parseJSONDocument(data []byte) (*node, error) {
+	p := &jsonParser{
+		data:       data,
+		lineStarts: lineStarts(data),
+		dec:        json.NewDecoder(bytes.NewReader(data)),
+	}
+	p.dec.UseNumber()
+
+	root, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := p.next(); err != io.EOF {
+		if err != nil {
+			return nil, err
+		}
+		return nil, &PositionError{Position: p.position(int(p.dec.InputOffset())), Message: "unexpected data after top-level value"}
+	}
+
+	return root, nil
+}
+
+func (p *jsonParser) next() (json.Token, Position, error) {
+	start := p.skipSeparators(int(p.dec.InputOffset()))
+	tok, err := p.dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			return nil, Position{}, err
+		}
+		offset := start
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			offset = int(syntaxErr.Offset)
+		}
+		return nil, Position{}, &PositionError{Position: p.position(offset), Message: err.Error()}
+	}
+	return tok, p.position(start), nil
+}
+
+func (p *jsonParser) parseValue() (*node, error) {
+	tok, pos, err := p.next()
+	if err != nil {
+		if err == io.EOF {
+			return nil, &PositionError{Position: p.position(len(p.data)), Message: "unexpected end of input"}
+		}
+		return nil, err
+	}
+
+	switch value := tok.(type) {
+	case json.Delim:
+		switch value {
+		case '{':
+			return p.parseObject(pos)
+		case '[':
+			return p.parseArray(pos)
+		}
+		return nil, &PositionError{Position: pos, Message: fmt.Sprintf("unexpected %q", rune(value))}
+	case string:
+		return &node{kind: stringNode, pos: pos, value: value}, nil
+	case json.Number:
+		return &node{kind: numberNode, pos: pos, value: value.String()}, nil
+	case bool:
+		return &node{kind: boolNode, pos: pos, value: fmt.Sprint(value)}, nil
+	default:
+		return &node{kind: nullNode, pos: pos}, nil
+	}
+}
+
+func (p *jsonParser) parseObject(pos Position) (*node, error) {
+	n := &node{kind: objectNode, pos: pos}
+	for p.dec.More() {
+		tok, keyPos, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		n.members = append(n.members, member{key: key, pos: keyPos, value: value})
+	}
+
+	if _, _, err := p.next(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (p *jsonParser) parseArray(pos Position) (*node, error) {
+	n := &node{kind: arrayNode, pos: pos}
+	for p.dec.More() {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, value)
+	}
+
+	if _, _, err := p.next(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// skipSeparators moves past whitespace, commas and colons, which the decoder
+// consumes implicitly, so offsets point at the first byte of the next token.
+func (p *jsonParser) skipSeparators(offset int) int {
+	for offset < len(p.data) {
+		switch p.data[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+func (p *jsonParser) position(offset int) Position {
+	return offsetPosition(p.lineStarts, offset)
+}
+
+func lineStarts(data []byte) []int {
+	starts := []int{0}
+	for i, b := range data {
+		if b == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+func offsetPosition(starts []int, offset int) Position {
+	line := 0
+	for line+1 < len(starts) && starts[line+1] <= offset {
+		line++
+	}
+	return Position{Line: line + 1, Column: offset - starts[line] + 1}
+}
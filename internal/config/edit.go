@@ -0,0 +1,102 @@
+package config
+
+import (
+	"aem/pkg/errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SetValue replaces the value of the top-level field in the config file at
+// configPath, keeping the rest of the file, comments included, as written.
+func SetValue(configPath, field, value string) error {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return errors.NewFileSystemError("failed to read "+configPath, err)
+	}
+	root, err := parseProjectDocument(configPath, data)
+	if err != nil {
+		return errors.NewValidationError(fmt.Sprintf("invalid project config %s: %v", configPath, err))
+	}
+
+	var target *member
+	for i := range root.members {
+		if root.members[i].key == field {
+			target = &root.members[i]
+			break
+		}
+	}
+	if target == nil {
+		return errors.NewValidationError(fmt.Sprintf("%s does not set %q", configPath, field))
+	}
+
+	starts := lineStarts(data)
+	pos := target.value.pos
+	if formatForPath(configPath) == formatTOML {
+		// TOML positions point at the key, and fall back to an enclosing
+		// table when the key cannot be found; the value follows the "="
+		var ok bool
+		if pos, ok = newTOMLLocator(data).find([]string{field}); !ok {
+			return errors.NewValidationError(fmt.Sprintf("cannot locate %q in %s", field, configPath))
+		}
+	}
+	if pos.Line < 1 || pos.Line > len(starts) {
+		return errors.NewValidationError(fmt.Sprintf("cannot locate %q in %s", field, configPath))
+	}
+	start := starts[pos.Line-1] + pos.Column - 1
+	if formatForPath(configPath) == formatTOML {
+		eq := strings.IndexByte(string(data[start:]), '=')
+		if eq < 0 {
+			return errors.NewValidationError(fmt.Sprintf("cannot locate %q in %s", field, configPath))
+		}
+		start += eq + 1
+		for start < len(data) && (data[start] == ' ' || data[start] == '\t') {
+			start++
+		}
+	}
+
+	end, quote := scalarEnd(data, start)
+	if quote == 0 {
+		quote = '"'
+	}
+	replaced := string(data[:start]) + string(quote) + value + string(quote) + string(data[end:])
+
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return errors.NewFileSystemError("failed to stat "+configPath, err)
+	}
+	tmpPath := filepath.Join(filepath.Dir(configPath), "."+filepath.Base(configPath)+".tmp")
+	if err := os.WriteFile(tmpPath, []byte(replaced), info.Mode().Perm()); err != nil {
+		return errors.FileWriteSystemError("failed to write "+configPath, err)
+	}
+	if err := os.Rename(tmpPath, configPath); err != nil {
+		os.Remove(tmpPath)
+		return errors.FileWriteSystemError("failed to write "+configPath, err)
+	}
+	return nil
+}
+
+// scalarEnd returns the offset just past the scalar starting at start, and
+// its quote character, or 0 for an unquoted scalar.
+func scalarEnd(data []byte, start int) (int, byte) {
+	if start < len(data) && (data[start] == '"' || data[start] == '\'') {
+		quote := data[start]
+		for i := start + 1; i < len(data); i++ {
+			if data[i] == '\\' && quote == '"' {
+				i++
+				continue
+			}
+			if data[i] == quote {
+				return i + 1, quote
+			}
+		}
+		return len(data), quote
+	}
+
+	end := start
+	for end < len(data) && !strings.ContainsRune(" \t\r\n,}#", rune(data[end])) {
+		end++
+	}
+	return end, 0
+}
@@ -0,0 +1,300 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+type configFormat string
+
+const (
+	formatJSON configFormat = "json"
+	formatYAML configFormat = "yaml"
+	formatTOML configFormat = "toml"
+)
+
+func formatForPath(configPath string) configFormat {
+	switch strings.ToLower(filepath.Ext(configPath)) {
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".toml":
+		return formatTOML
+	default:
+		return formatJSON
+	}
+}
+
+// parseDocument parses a project config in any supported format into the
+// position-aware node tree shared by validation and decoding.
+func parseDocument(data []byte, format configFormat) (*node, error) {
+	switch format {
+	case formatYAML:
+		return parseYAMLDocument(data)
+	case formatTOML:
+		return parseTOMLDocument(data)
+	default:
+		return parseJSONDocument(data)
+	}
+}
+
+func parseYAMLDocument(data []byte) (*node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, &PositionError{Position: yamlErrorPosition(err), Message: err.Error()}
+	}
+
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		return &node{kind: objectNode, pos: Position{Line: 1, Column: 1}}, nil
+	}
+
+	return convertYAMLNode(doc.Content[0])
+}
+
+func convertYAMLNode(y *yaml.Node) (*node, error) {
+	pos := Position{Line: y.Line, Column: y.Column}
+
+	switch y.Kind {
+	case yaml.DocumentNode:
+		if len(y.Content) == 0 {
+			return &node{kind: nullNode, pos: pos}, nil
+		}
+		return convertYAMLNode(y.Content[0])
+	case yaml.AliasNode:
+		return convertYAMLNode(y.Alias)
+	case yaml.MappingNode:
+		n := &node{kind: objectNode, pos: pos}
+		for i := 0; i+1 < len(y.Content); i += 2 {
+			key := y.Content[i]
+			value, err := convertYAMLNode(y.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			n.members = append(n.members, member{
+				key:   key.Value,
+				pos:   Position{Line: key.Line, Column: key.Column},
+				value: value,
+			})
+		}
+		return n, nil
+	case yaml.SequenceNode:
+		n := &node{kind: arrayNode, pos: pos}
+		for _, item := range y.Content {
+			value, err := convertYAMLNode(item)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, value)
+		}
+		return n, nil
+	default:
+		switch y.ShortTag() {
+		case "!!null":
+			return &node{kind: nullNode, pos: pos}, nil
+		case "!!bool":
+			var value bool
+			if err := y.Decode(&value); err != nil {
+				return nil, &PositionError{Position: pos, Message: err.Error()}
+			}
+			return &node{kind: boolNode, pos: pos, value: fmt.Sprint(value)}, nil
+		case "!!int", "!!float":
+			return &node{kind: numberNode, pos: pos, value: y.Value}, nil
+		default:
+			return &node{kind: stringNode, pos: pos, value: y.Value}, nil
+		}
+	}
+}
+
+var yamlLinePattern = regexp.MustCompile(`line ([0-9]+)`)
+
+func yamlErrorPosition(err error) Position {
+	match := yamlLinePattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return Position{Line: 1, Column: 1}
+	}
+	var line int
+	fmt.Sscanf(match[1], "%d", &line)
+	return Position{Line: line, Column: 1}
+}
+
+func parseTOMLDocument(data []byte) (*node, error) {
+	var raw map[string]interface{}
+	if _, err := toml.Decode(string(data), &raw); err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &PositionError{
+				Position: Position{Line: parseErr.Position.Line, Column: parseErr.Position.Col},
+				Message:  parseErr.Message,
+			}
+		}
+		return nil, &PositionError{Position: Position{Line: 1, Column: 1}, Message: err.Error()}
+	}
+
+	locator := newTOMLLocator(data)
+	return convertTOMLValue(raw, nil, locator), nil
+}
+
+func convertTOMLValue(value interface{}, key []string, locator *tomlLocator) *node {
+	pos := locator.position(key)
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		n := &node{kind: objectNode, pos: pos}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			childKey := append(append([]string{}, key...), k)
+			n.members = append(n.members, member{
+				key:   k,
+				pos:   locator.position(childKey),
+				value: convertTOMLValue(v[k], childKey, locator),
+			})
+		}
+		return n
+	case []map[string]interface{}:
+		n := &node{kind: arrayNode, pos: pos}
+		for _, item := range v {
+			n.items = append(n.items, convertTOMLValue(item, key, locator))
+		}
+		return n
+	case []interface{}:
+		n := &node{kind: arrayNode, pos: pos}
+		for _, item := range v {
+			n.items = append(n.items, convertTOMLValue(item, key, locator))
+		}
+		return n
+	case string:
+		return &node{kind: stringNode, pos: pos, value: v}
+	case bool:
+		return &node{kind: boolNode, pos: pos, value: fmt.Sprint(v)}
+	case int64:
+		return &node{kind: numberNode, pos: pos, value: fmt.Sprint(v)}
+	case float64:
+		return &node{kind: numberNode, pos: pos, value: fmt.Sprint(v), float: true}
+	default:
+		return &node{kind: stringNode, pos: pos, value: fmt.Sprint(v)}
+	}
+}
+
+// tomlLocator recovers approximate key positions, which the TOML decoder
+// does not expose, by scanning table headers and key assignments.
+type tomlLocator struct {
+	lines []string
+}
+
+func newTOMLLocator(data []byte) *tomlLocator {
+	return &tomlLocator{lines: strings.Split(string(data), "\n")}
+}
+
+func (l *tomlLocator) position(key []string) Position {
+	if len(key) == 0 {
+		return Position{Line: 1, Column: 1}
+	}
+	if pos, ok := l.find(key); ok {
+		return pos
+	}
+	return l.position(key[:len(key)-1])
+}
+
+// find returns the position of the table header or assignment of key.
+func (l *tomlLocator) find(key []string) (Position, bool) {
+	table := ""
+	for i, line := range l.lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			table = strings.Trim(trimmed, "[] ")
+			if table == strings.Join(key, ".") {
+				return Position{Line: i + 1, Column: strings.Index(line, "[") + 1}, true
+			}
+			continue
+		}
+
+		name, _, ok := strings.Cut(trimmed, "=")
+		if !ok {
+			continue
+		}
+		name = strings.Trim(strings.TrimSpace(name), `"'`)
+
+		full := name
+		if table != "" {
+			full = table + "." + name
+		}
+		if full == strings.Join(key, ".") {
+			return Position{Line: i + 1, Column: strings.Index(line, strings.TrimLeft(line, " \t")) + 1}, true
+		}
+	}
+	return Position{}, false
+}
+
+// coerceScalars turns YAML and TOML numbers into strings wherever the config
+// expects a string, so `node: 18` behaves like `"node": "18"` in JSON. TOML
+// floats are left as numbers for validation to reject.
+func coerceScalars(n *node, t reflect.Type) {
+	switch {
+	case t == stringListType, t.Kind() == reflect.String:
+		if n.kind == numberNode && !n.float {
+			n.kind = stringNode
+		}
+		for _, item := range n.items {
+			if item.kind == numberNode && !item.float {
+				item.kind = stringNode
+			}
+		}
+	case t.Kind() == reflect.Slice:
+		for _, item := range n.items {
+			coerceScalars(item, t.Elem())
+		}
+	case t.Kind() == reflect.Map:
+		for _, m := range n.members {
+			coerceScalars(m.value, t.Elem())
+		}
+	case t.Kind() == reflect.Struct:
+		fields := structFields(t)
+		for _, m := range n.members {
+			if field, ok := lookupField(fields, m.key); ok {
+				coerceScalars(m.value, field.typ)
+			}
+		}
+	}
+}
+
+// toInterface converts a node tree into plain values suitable for json.Marshal,
+// so every format decodes through the same JSON unmarshalers.
+func (n *node) toInterface() interface{} {
+	switch n.kind {
+	case objectNode:
+		values := make(map[string]interface{}, len(n.members))
+		for _, m := range n.members {
+			values[m.key] = m.value.toInterface()
+		}
+		return values
+	case arrayNode:
+		values := make([]interface{}, 0, len(n.items))
+		for _, item := range n.items {
+			values = append(values, item.toInterface())
+		}
+		return values
+	case stringNode:
+		return n.value
+	case numberNode:
+		if json.Valid([]byte(n.value)) {
+			return json.Number(n.value)
+		}
+		return n.value
+	case boolNode:
+		return n.value == "true"
+	default:
+		return nil
+	}
+}
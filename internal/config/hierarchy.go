@@ -0,0 +1,287 @@
+package config
+
+import (
+	"aem/pkg/errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ResolvedConfig is the effective project config after merging every config
+// file it inherits from.
+type ResolvedConfig struct {
+	Config *ProjectConfig
+	// Files lists the merged config files from the outermost to the nearest.
+	Files []string
+	// Sources maps dotted field paths such as "android.sdk" or "env.CI" to the
+	// file that provided the effective value.
+	Sources map[string]string
+}
+
+// Path returns the nearest config file, which the resolution started from.
+func (r *ResolvedConfig) Path() string {
+	return r.Files[len(r.Files)-1]
+}
+
+// Dir returns the directory of the nearest config file.
+func (r *ResolvedConfig) Dir() string {
+	return filepath.Dir(r.Path())
+}
+
+type ResolvedValue struct {
+	Field  string
+	Value  string
+	Source string
+}
+
+// ResolveProjectConfig finds the nearest project config and merges it with the
+// configs it inherits from. A config inherits from another either explicitly
+// via "extends", or implicitly from the nearest config in a parent directory
+// when an ancestor config is marked "root": true. Nearer configs win, except
+// that path entries from every config are kept, nearest first.
+func ResolveProjectConfig(startDir string) (*ResolvedConfig, error) {
+	nearest, err := FindProjectConfig(startDir)
+	if err != nil {
+		return nil, err
+	}
+	return ResolveProjectConfigFile(nearest)
+}
+
+// ResolveProjectConfigFile resolves the inheritance chain starting at configPath.
+func ResolveProjectConfigFile(configPath string) (*ResolvedConfig, error) {
+	var chain []string
+	var layers []*ProjectConfig
+	visited := make(map[string]bool)
+
+	current, err := filepath.Abs(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", configPath, err)
+	}
+
+	for current != "" {
+		if visited[current] {
+			return nil, errors.NewValidationError("circular \"extends\" chain: " + strings.Join(append(chain, current), " -> "))
+		}
+		visited[current] = true
+
+		cfg, err := LoadProjectConfig(current)
+		if err != nil {
+			return nil, err
+		}
+		absolutizePaths(cfg, filepath.Dir(current))
+
+		chain = append(chain, current)
+		layers = append(layers, cfg)
+
+		if cfg.Root {
+			break
+		}
+
+		if cfg.Extends != "" {
+			parent, err := resolveExtends(filepath.Dir(current), cfg.Extends)
+			if err != nil {
+				return nil, fmt.Errorf("invalid \"extends\" in %s: %w", current, err)
+			}
+			current = parent
+			continue
+		}
+
+		current, err = implicitParent(current)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resolved := &ResolvedConfig{
+		Config:  &ProjectConfig{},
+		Sources: make(map[string]string),
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		mergeValue(reflect.ValueOf(resolved.Config).Elem(), reflect.ValueOf(layers[i]).Elem(), "", chain[i], resolved.Sources)
+		resolved.Files = append(resolved.Files, chain[i])
+	}
+
+	return resolved, nil
+}
+
+// Values flattens the effective config into sorted field/value/source rows.
+func (r *ResolvedConfig) Values() []ResolvedValue {
+	var values []ResolvedValue
+	flattenValue(reflect.ValueOf(r.Config).Elem(), "", func(field, value string) {
+		values = append(values, ResolvedValue{Field: field, Value: value, Source: r.Sources[field]})
+	})
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Field < values[j].Field
+	})
+	return values
+}
+
+func resolveExtends(dir, extends string) (string, error) {
+	target := extends
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(dir, target)
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return "", fmt.Errorf("%s does not exist", target)
+	}
+	if !info.IsDir() {
+		return target, nil
+	}
+
+	for _, name := range ProjectConfigFileNames {
+		candidate := filepath.Join(target, name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no project config found in %s", target)
+}
+
+// implicitParent returns the nearest config above configPath, but only when
+// some ancestor is marked as the root; otherwise configs stay independent.
+func implicitParent(configPath string) (string, error) {
+	dir := filepath.Dir(filepath.Dir(configPath))
+	if dir == filepath.Dir(configPath) {
+		return "", nil
+	}
+
+	parent, err := FindProjectConfig(dir)
+	if err != nil {
+		return "", nil
+	}
+
+	for ancestor := parent; ancestor != ""; {
+		cfg, err := LoadProjectConfig(ancestor)
+		if err != nil {
+			return "", err
+		}
+		if cfg.Root {
+			return parent, nil
+		}
+
+		next := filepath.Dir(filepath.Dir(ancestor))
+		if next == filepath.Dir(ancestor) {
+			break
+		}
+		ancestor, err = FindProjectConfig(next)
+		if err != nil {
+			break
+		}
+	}
+
+	return "", nil
+}
+
+// absolutizePaths resolves relative path entries against the directory of the
+// file that declared them, so they survive merging with other configs.
+func absolutizePaths(cfg *ProjectConfig, dir string) {
+	absolutize := func(entries StringList) {
+		for i, entry := range entries {
+			entry = strings.TrimSpace(entry)
+			if entry == "" || filepath.IsAbs(entry) || strings.HasPrefix(entry, "$") {
+				continue
+			}
+			entries[i] = filepath.Join(dir, entry)
+		}
+	}
+
+	absolutize(cfg.Path)
+	for _, profile := range cfg.Profiles {
+		absolutize(profile.Path)
+	}
+}
+
+// localFields describe a single config file and are not merged.
+var localFields = map[string]bool{
+	"$schema": true,
+	"extends": true,
+	"root":    true,
+}
+
+func mergeValue(dst, src reflect.Value, path, source string, sources map[string]string) {
+	switch dst.Kind() {
+	case reflect.Struct:
+		for _, field := range structFields(dst.Type()) {
+			if path == "" && localFields[field.name] {
+				continue
+			}
+			fieldPath := joinPath(path, field.name)
+			if field.name == "path" {
+				mergePathList(dst.Field(field.index), src.Field(field.index), fieldPath, source, sources)
+				continue
+			}
+			mergeValue(dst.Field(field.index), src.Field(field.index), fieldPath, source, sources)
+		}
+		return
+	case reflect.Map:
+		if src.Len() == 0 {
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMap(dst.Type()))
+		}
+		for _, key := range src.MapKeys() {
+			keyPath := joinPath(path, key.String())
+			if dst.Type().Elem().Kind() == reflect.Struct {
+				merged := reflect.New(dst.Type().Elem()).Elem()
+				if existing := dst.MapIndex(key); existing.IsValid() {
+					merged.Set(existing)
+				}
+				mergeValue(merged, src.MapIndex(key), keyPath, source, sources)
+				dst.SetMapIndex(key, merged)
+				continue
+			}
+			dst.SetMapIndex(key, src.MapIndex(key))
+			sources[keyPath] = source
+		}
+		return
+	}
+
+	if !src.IsZero() {
+		dst.Set(src)
+		sources[path] = source
+	}
+}
+
+// mergePathList puts the nearer config's path entries ahead of the inherited
+// ones instead of replacing them, so shared tool directories stay on PATH.
+func mergePathList(dst, src reflect.Value, path, source string, sources map[string]string) {
+	overlay := src.Interface().(StringList)
+	if len(overlay) == 0 {
+		return
+	}
+
+	inherited := dst.Interface().(StringList)
+	dst.Set(reflect.ValueOf(mergeStringLists(overlay, inherited)))
+	if existing := sources[path]; existing != "" {
+		sources[path] = source + ", " + existing
+		return
+	}
+	sources[path] = source
+}
+
+func flattenValue(v reflect.Value, path string, emit func(field, value string)) {
+	switch {
+	case v.Type() == stringListType:
+		if v.Len() > 0 {
+			emit(path, strings.Join(v.Interface().(StringList), ", "))
+		}
+	case v.Kind() == reflect.Struct:
+		for _, field := range structFields(v.Type()) {
+			flattenValue(v.Field(field.index), joinPath(path, field.name), emit)
+		}
+	case v.Kind() == reflect.Map:
+		for _, key := range v.MapKeys() {
+			flattenValue(v.MapIndex(key), joinPath(path, key.String()), emit)
+		}
+	default:
+		if !v.IsZero() {
+			emit(path, fmt.Sprint(v.Interface()))
+		}
+	}
+}
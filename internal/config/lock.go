@@ -0,0 +1,93 @@
+package config
+
+import (
+	"aem/pkg/errors"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LockFileName is the file next to a project config that records the exact
+// versions floating specs such as "node": "18" were last upgraded to.
+const LockFileName = "aem.lock"
+
+// LockPath returns the lock file belonging to the config at configPath.
+func LockPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), LockFileName)
+}
+
+// LoadLock returns the versions recorded in the lock file at path, keyed by
+// config field. A missing lock file records nothing.
+func LoadLock(path string) (map[string]string, error) {
+	locked := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return locked, nil
+		}
+		return nil, errors.NewFileSystemError("failed to read "+path, err)
+	}
+	if err := json.Unmarshal(data, &locked); err != nil {
+		return nil, errors.NewValidationError(fmt.Sprintf("invalid lock file %s: %v", path, err))
+	}
+	return locked, nil
+}
+
+// SetLocked records version for field in the lock file at path, keeping the
+// other entries.
+func SetLocked(path, field, version string) error {
+	locked, err := LoadLock(path)
+	if err != nil {
+		return err
+	}
+	locked[field] = version
+
+	data, err := json.MarshalIndent(locked, "", "  ")
+	if err != nil {
+		return errors.NewValidationError(fmt.Sprintf("failed to encode %s: %v", path, err))
+	}
+	tmpPath := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmpPath, append(data, '\n'), 0644); err != nil {
+		return errors.FileWriteSystemError("failed to write "+path, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return errors.FileWriteSystemError("failed to write "+path, err)
+	}
+	return nil
+}
+
+// ApplyLock replaces the runtime versions of the config with the versions
+// locked for them, as long as a locked version still matches its spec. It
+// returns the fields it replaced.
+func (c *ProjectConfig) ApplyLock(locked map[string]string) []string {
+	var applied []string
+	for _, field := range []struct {
+		name string
+		spec *string
+	}{
+		{"node", &c.Node},
+		{"jdk", &c.JDK},
+		{"gradle", &c.Gradle},
+		{"ruby", &c.Ruby},
+		{"python", &c.Python},
+	} {
+		version, ok := locked[field.name]
+		if !ok || *field.spec == "" || !lockMatches(*field.spec, version) {
+			continue
+		}
+		*field.spec = version
+		applied = append(applied, field.name)
+	}
+	return applied
+}
+
+// lockMatches reports whether version satisfies spec exactly or as a prefix
+// such as "18" or "18.20".
+func lockMatches(spec, version string) bool {
+	spec = strings.TrimPrefix(strings.TrimSpace(spec), "v")
+	version = strings.TrimPrefix(version, "v")
+	return version == spec || strings.HasPrefix(version, spec+".")
+}
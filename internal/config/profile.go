@@ -0,0 +1,138 @@
+package config
+
+import (
+	"aem/pkg/errors"
+	"aem/pkg/settings"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ProfileConfig overlays the base project config when the profile is selected.
+// Runtime versions and env values replace the base values; Android packages,
+// global npm packages and path entries are added to them.
+type ProfileConfig struct {
+	Node           string            `json:"node,omitempty" aem:"version"`
+	PackageManager string            `json:"packageManager,omitempty" aem:"package-manager"`
+	GlobalPackages StringList        `json:"globalPackages,omitempty" aem:"npm-package"`
+	JDK            string            `json:"jdk,omitempty" aem:"version"`
+	Gradle         string            `json:"gradle,omitempty" aem:"version"`
+	Ruby           string            `json:"ruby,omitempty" aem:"version"`
+	Python         string            `json:"python,omitempty" aem:"version"`
+	Tools          map[string]string `json:"tools,omitempty" aem:"version"`
+	Android        AndroidConfig     `json:"android"`
+	Env            map[string]string `json:"env,omitempty"`
+	Path           StringList        `json:"path,omitempty"`
+}
+
+// ResolveProfile returns the explicitly requested profile, falling back to the
+// profile setting (AEM_PROFILE or the global config file).
+func ResolveProfile(requested string) string {
+	if value := strings.TrimSpace(requested); value != "" {
+		return value
+	}
+	cfg, _ := settings.Load()
+	return cfg.String("profile")
+}
+
+// WithProfile returns a copy of the config with the named profile applied.
+// An empty name returns the base config unchanged.
+func (c *ProjectConfig) WithProfile(name string) (*ProjectConfig, error) {
+	merged := *c
+	if name == "" {
+		return &merged, nil
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return nil, errors.NewValidationError(unknownProfileMessage(name, c.ProfileNames()))
+	}
+
+	if profile.Node != "" {
+		merged.Node = profile.Node
+	}
+	if profile.PackageManager != "" {
+		merged.PackageManager = profile.PackageManager
+	}
+	if profile.JDK != "" {
+		merged.JDK = profile.JDK
+	}
+	if profile.Gradle != "" {
+		merged.Gradle = profile.Gradle
+	}
+	if profile.Ruby != "" {
+		merged.Ruby = profile.Ruby
+	}
+	if profile.Python != "" {
+		merged.Python = profile.Python
+	}
+	if len(profile.Tools) > 0 {
+		merged.Tools = make(map[string]string, len(c.Tools)+len(profile.Tools))
+		for name, version := range c.Tools {
+			merged.Tools[name] = version
+		}
+		for name, version := range profile.Tools {
+			merged.Tools[name] = version
+		}
+	}
+	merged.Android = AndroidConfig{
+		SDK:       mergeStringLists(c.Android.SDK, profile.Android.SDK),
+		NDK:       mergeStringLists(c.Android.NDK, profile.Android.NDK),
+		BuildTool: mergeStringLists(c.Android.BuildTool, profile.Android.BuildTool),
+	}
+	merged.GlobalPackages = mergeStringLists(c.GlobalPackages, profile.GlobalPackages)
+	merged.Path = mergeStringLists(c.Path, profile.Path)
+	if len(profile.Env) > 0 {
+		merged.Env = make(map[string]string, len(c.Env)+len(profile.Env))
+		for name, value := range c.Env {
+			merged.Env[name] = value
+		}
+		for name, value := range profile.Env {
+			merged.Env[name] = value
+		}
+	}
+
+	return &merged, nil
+}
+
+func (c *ProjectConfig) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func mergeStringLists(base, overlay StringList) StringList {
+	if len(overlay) == 0 {
+		return base
+	}
+
+	seen := make(map[string]struct{}, len(base)+len(overlay))
+	var merged StringList
+	for _, value := range append(append(StringList{}, base...), overlay...) {
+		if _, exists := seen[value]; exists {
+			continue
+		}
+		seen[value] = struct{}{}
+		merged = append(merged, value)
+	}
+	return merged
+}
+
+func unknownProfileMessage(name string, available []string) string {
+	if len(available) == 0 {
+		return fmt.Sprintf("profile %q is not defined; the project config has no profiles", name)
+	}
+
+	message := fmt.Sprintf("profile %q is not defined (available: %s)", name, strings.Join(available, ", "))
+	fields := make([]fieldInfo, 0, len(available))
+	for _, candidate := range available {
+		fields = append(fields, fieldInfo{name: candidate})
+	}
+	if suggestion := suggestField(fields, name); suggestion != "" {
+		message += fmt.Sprintf("; did you mean %q?", suggestion)
+	}
+	return message
+}
@@ -1,24 +1,42 @@
 package config
 
 import (
+	"aem/pkg/errors"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 const ProjectConfigFileName = "aem.json"
 
+// ProjectConfigFileNames lists the supported project config files in order of
+// precedence when more than one exists in the same directory.
+var ProjectConfigFileNames = []string{ProjectConfigFileName, "aem.yaml", "aem.yml", "aem.toml"}
+
 type ProjectConfig struct {
-	Node    string        `json:"node"`
-	JDK     string        `json:"jdk"`
-	Android AndroidConfig `json:"android"`
+	Schema         string                   `json:"$schema,omitempty"`
+	Extends        string                   `json:"extends,omitempty"`
+	Root           bool                     `json:"root,omitempty"`
+	Node           string                   `json:"node,omitempty" aem:"version"`
+	PackageManager string                   `json:"packageManager,omitempty" aem:"package-manager"`
+	GlobalPackages StringList               `json:"globalPackages,omitempty" aem:"npm-package"`
+	JDK            string                   `json:"jdk,omitempty" aem:"version"`
+	Gradle         string                   `json:"gradle,omitempty" aem:"version"`
+	Ruby           string                   `json:"ruby,omitempty" aem:"version"`
+	Python         string                   `json:"python,omitempty" aem:"version"`
+	Tools          map[string]string        `json:"tools,omitempty" aem:"version"`
+	Android        AndroidConfig            `json:"android"`
+	Env            map[string]string        `json:"env,omitempty"`
+	Path           StringList               `json:"path,omitempty"`
+	Profiles       map[string]ProfileConfig `json:"profiles,omitempty"`
 }
 
 type AndroidConfig struct {
-	SDK       StringList `json:"sdk"`
-	NDK       StringList `json:"ndk"`
-	BuildTool StringList `json:"build-tool"`
+	SDK       StringList `json:"sdk,omitempty" aem:"android-sdk"`
+	NDK       StringList `json:"ndk,omitempty" aem:"android-ndk"`
+	BuildTool StringList `json:"build-tool,omitempty" aem:"android-build-tool"`
 }
 
 type StringList []string
@@ -54,14 +72,16 @@ func FindProjectConfig(startDir string) (string, error) {
 
 	current := startDir
 	for {
-		candidate := filepath.Join(current, ProjectConfigFileName)
-		if _, err := os.Stat(candidate); err == nil {
-			return candidate, nil
+		for _, name := range ProjectConfigFileNames {
+			candidate := filepath.Join(current, name)
+			if _, err := os.Stat(candidate); err == nil {
+				return candidate, nil
+			}
 		}
 
 		parent := filepath.Dir(current)
 		if parent == current {
-			return "", fmt.Errorf("%s not found in %s or any parent directory", ProjectConfigFileName, startDir)
+			return "", fmt.Errorf("%s not found in %s or any parent directory", strings.Join(ProjectConfigFileNames, ", "), startDir)
 		}
 		current = parent
 	}
@@ -73,6 +93,21 @@ func LoadProjectConfig(configPath string) (*ProjectConfig, error) {
 		return nil, fmt.Errorf("failed to read %s: %w", configPath, err)
 	}
 
+	root, err := parseProjectDocument(configPath, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", configPath, err)
+	}
+	if issues := validateDocument(root); len(issues) > 0 {
+		return nil, errors.NewValidationError(formatIssues(configPath, issues))
+	}
+
+	if formatForPath(configPath) != formatJSON {
+		data, err = json.Marshal(root.toInterface())
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s: %w", configPath, err)
+		}
+	}
+
 	var cfg ProjectConfig
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse %s: %w", configPath, err)
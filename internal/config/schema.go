@@ -0,0 +1,61 @@
+package config
+
+import (
+	"reflect"
+)
+
+const schemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// ProjectConfigSchema returns a JSON Schema describing aem.json, generated
+// from the same struct tags used by ValidateProjectConfig.
+func ProjectConfigSchema() map[string]interface{} {
+	schema := typeSchema(reflect.TypeOf(ProjectConfig{}), "")
+	schema["$schema"] = schemaDraft
+	schema["title"] = ProjectConfigFileName
+	schema["description"] = "Project toolchain configuration for the Adaptive Environment Manager."
+	return schema
+}
+
+func typeSchema(t reflect.Type, rule string) map[string]interface{} {
+	switch {
+	case t == stringListType:
+		item := valueSchema(rule)
+		return map[string]interface{}{
+			"anyOf": []interface{}{
+				item,
+				map[string]interface{}{"type": "array", "items": item},
+			},
+		}
+	case t.Kind() == reflect.String:
+		return valueSchema(rule)
+	case t.Kind() == reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case t.Kind() == reflect.Int:
+		return map[string]interface{}{"type": "integer"}
+	case t.Kind() == reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), rule)}
+	case t.Kind() == reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem(), rule)}
+	case t.Kind() == reflect.Struct:
+		properties := make(map[string]interface{})
+		for _, field := range structFields(t) {
+			properties[field.name] = typeSchema(field.typ, field.rule)
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func valueSchema(rule string) map[string]interface{} {
+	schema := map[string]interface{}{"type": "string"}
+	if value, ok := valueRules[rule]; ok {
+		schema["pattern"] = value.pattern
+		schema["description"] = value.description
+	}
+	return schema
+}
@@ -0,0 +1,327 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Issue describes a single problem found while validating a project config.
+type Issue struct {
+	Path    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (i Issue) String() string {
+	if i.Path == "" {
+		return fmt.Sprintf("%d:%d: %s", i.Line, i.Column, i.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", i.Line, i.Column, i.Path, i.Message)
+}
+
+type valueRule struct {
+	pattern     string
+	description string
+}
+
+// valueRules are referenced from `aem:"..."` struct tags and shared between
+// validation and the generated JSON Schema.
+var valueRules = map[string]valueRule{
+	"version": {
+		pattern:     `^(v?[0-9]+(\.[0-9]+){0,2}|[A-Za-z][A-Za-z0-9._-]*)$`,
+		description: "Runtime version: a major version such as \"18\", an exact release such as \"18.19.1\", or the name of a runtime registered with 'aem link'.",
+	},
+	"package-manager": {
+		pattern:     `^(npm|yarn|pnpm)@[0-9]+(\.[0-9]+){0,2}([-+][0-9A-Za-z.+-]+)?$`,
+		description: "Node package manager as in package.json, such as \"yarn@3.6.4\" or \"pnpm@8.15.1\".",
+	},
+	"npm-package": {
+		pattern:     `^(@[a-z0-9][a-z0-9._~-]*/)?[a-z0-9][a-z0-9._~-]*(@[0-9A-Za-z.^~<>=*+-]+)?$`,
+		description: "npm package installed globally, optionally with a version or tag, such as \"eas-cli\" or \"@expo/ngrok@4\".",
+	},
+	"android-sdk": {
+		pattern:     `^([0-9]+(-ext[0-9]+)?|[a-z][a-z0-9-]*(;[A-Za-z0-9._-]+)+)$`,
+		description: "Android API level such as \"34\", or a full sdkmanager package path.",
+	},
+	"android-ndk": {
+		pattern:     `^([0-9]+\.[0-9]+\.[0-9]+(-[A-Za-z0-9]+)?|[a-z][a-z0-9-]*(;[A-Za-z0-9._-]+)+)$`,
+		description: "NDK version such as \"25.1.8937393\", or a full sdkmanager package path.",
+	},
+	"android-build-tool": {
+		pattern:     `^([0-9]+\.[0-9]+\.[0-9]+(-rc[0-9]+)?|[a-z][a-z0-9-]*(;[A-Za-z0-9._-]+)+)$`,
+		description: "Build-tools version such as \"34.0.0\", or a full sdkmanager package path.",
+	},
+}
+
+var compiledRules = func() map[string]*regexp.Regexp {
+	compiled := make(map[string]*regexp.Regexp, len(valueRules))
+	for name, rule := range valueRules {
+		compiled[name] = regexp.MustCompile(rule.pattern)
+	}
+	return compiled
+}()
+
+// fieldAliases maps common misspellings to the field they most likely meant.
+var fieldAliases = map[string]string{
+	"java":            "jdk",
+	"nodejs":          "node",
+	"package-manager": "packageManager",
+	"packagemanager":  "packageManager",
+	"global-packages": "globalPackages",
+	"globalpackages":  "globalPackages",
+	"globals":         "globalPackages",
+	"build-tools":     "build-tool",
+	"buildtool":       "build-tool",
+	"buildtools":      "build-tool",
+	"build_tool":      "build-tool",
+	"build_tools":     "build-tool",
+}
+
+type fieldInfo struct {
+	name  string
+	rule  string
+	typ   reflect.Type
+	index int
+}
+
+var stringListType = reflect.TypeOf(StringList{})
+
+var projectConfigType = reflect.TypeOf(ProjectConfig{})
+
+// ValidateProjectConfig checks a project config for syntax errors, unknown
+// fields and malformed values. The format is chosen from the file extension.
+func ValidateProjectConfig(configPath string, data []byte) ([]Issue, error) {
+	root, err := parseProjectDocument(configPath, data)
+	if err != nil {
+		return nil, err
+	}
+	return validateDocument(root), nil
+}
+
+func parseProjectDocument(configPath string, data []byte) (*node, error) {
+	format := formatForPath(configPath)
+	root, err := parseDocument(data, format)
+	if err != nil {
+		return nil, err
+	}
+	if format != formatJSON {
+		coerceScalars(root, projectConfigType)
+	}
+	return root, nil
+}
+
+func validateDocument(root *node) []Issue {
+	var issues []Issue
+	validateNode(root, projectConfigType, "", "", &issues)
+	return issues
+}
+
+func validateNode(n *node, t reflect.Type, path, rule string, issues *[]Issue) {
+	report := func(pos Position, format string, args ...interface{}) {
+		*issues = append(*issues, Issue{
+			Path:    path,
+			Line:    pos.Line,
+			Column:  pos.Column,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	switch {
+	case t == stringListType:
+		switch n.kind {
+		case stringNode:
+			validateValue(n, rule, report)
+		case arrayNode:
+			for _, item := range n.items {
+				if item.float {
+					report(item.pos, "expected string, found float %s (quote it, TOML floats drop trailing zeros)", item.value)
+					continue
+				}
+				if item.kind != stringNode {
+					report(item.pos, "expected string, found %s", item.kind)
+					continue
+				}
+				validateValue(item, rule, report)
+			}
+		default:
+			report(n.pos, "expected string or array of strings, found %s", n.kind)
+		}
+	case t.Kind() == reflect.String:
+		if n.float {
+			report(n.pos, "expected string, found float %s (quote it, TOML floats drop trailing zeros)", n.value)
+			return
+		}
+		if n.kind != stringNode {
+			report(n.pos, "expected string, found %s", n.kind)
+			return
+		}
+		validateValue(n, rule, report)
+	case t.Kind() == reflect.Bool:
+		if n.kind != boolNode {
+			report(n.pos, "expected boolean, found %s", n.kind)
+		}
+	case t.Kind() == reflect.Int:
+		if n.kind != numberNode {
+			report(n.pos, "expected number, found %s", n.kind)
+		}
+	case t.Kind() == reflect.Slice:
+		if n.kind != arrayNode {
+			report(n.pos, "expected array, found %s", n.kind)
+			return
+		}
+		for i, item := range n.items {
+			validateNode(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), rule, issues)
+		}
+	case t.Kind() == reflect.Map:
+		if n.kind != objectNode {
+			report(n.pos, "expected object, found %s", n.kind)
+			return
+		}
+		for _, m := range n.members {
+			validateNode(m.value, t.Elem(), joinPath(path, m.key), rule, issues)
+		}
+	case t.Kind() == reflect.Struct:
+		if n.kind != objectNode {
+			report(n.pos, "expected object, found %s", n.kind)
+			return
+		}
+		fields := structFields(t)
+		seen := make(map[string]bool, len(n.members))
+		for _, m := range n.members {
+			fieldPath := joinPath(path, m.key)
+			if seen[m.key] {
+				*issues = append(*issues, Issue{Path: fieldPath, Line: m.pos.Line, Column: m.pos.Column, Message: "duplicate field"})
+				continue
+			}
+			seen[m.key] = true
+
+			field, ok := lookupField(fields, m.key)
+			if !ok {
+				message := fmt.Sprintf("unknown field %q", m.key)
+				if suggestion := suggestField(fields, m.key); suggestion != "" {
+					message += fmt.Sprintf(" (did you mean %q?)", suggestion)
+				}
+				*issues = append(*issues, Issue{Path: path, Line: m.pos.Line, Column: m.pos.Column, Message: message})
+				continue
+			}
+			validateNode(m.value, field.typ, fieldPath, field.rule, issues)
+		}
+	}
+}
+
+func validateValue(n *node, rule string, report func(Position, string, ...interface{})) {
+	if rule == "" {
+		return
+	}
+	pattern, ok := compiledRules[rule]
+	if !ok {
+		return
+	}
+	value := strings.TrimSpace(n.value)
+	if value == "" {
+		return
+	}
+	if !pattern.MatchString(value) {
+		report(n.pos, "invalid value %q: %s", n.value, valueRules[rule].description)
+	}
+}
+
+func structFields(t reflect.Type) []fieldInfo {
+	var fields []fieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, fieldInfo{name: name, rule: f.Tag.Get("aem"), typ: f.Type, index: i})
+	}
+	return fields
+}
+
+func lookupField(fields []fieldInfo, name string) (fieldInfo, bool) {
+	for _, field := range fields {
+		if field.name == name {
+			return field, true
+		}
+	}
+	return fieldInfo{}, false
+}
+
+func suggestField(fields []fieldInfo, name string) string {
+	if alias, ok := fieldAliases[strings.ToLower(name)]; ok {
+		if _, exists := lookupField(fields, alias); exists {
+			return alias
+		}
+	}
+
+	best := ""
+	bestDistance := 0
+	for _, field := range fields {
+		distance := levenshtein(strings.ToLower(name), strings.ToLower(field.name))
+		if best == "" || distance < bestDistance {
+			best = field.name
+			bestDistance = distance
+		}
+	}
+
+	if best == "" || bestDistance > 2 || bestDistance >= len(name) {
+		return ""
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+func joinPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+func formatIssues(configPath string, issues []Issue) string {
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Line != issues[j].Line {
+			return issues[i].Line < issues[j].Line
+		}
+		return issues[i].Column < issues[j].Column
+	})
+
+	lines := make([]string, 0, len(issues)+1)
+	lines = append(lines, "invalid project config "+configPath)
+	for _, issue := range issues {
+		lines = append(lines, "  "+configPath+":"+issue.String())
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,277 @@
+package environment
+
+import (
+	"aem/internal/config"
+	"aem/internal/platform"
+	"aem/pkg/errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Options describes the active runtime links and the project whose env and
+// path entries should be applied on top of them.
+type Options struct {
+	AEMHome     string
+	NodeHome    string
+	JavaHome    string
+	GradleHome  string
+	RubyHome    string
+	PythonHome  string
+	AndroidHome string
+	Tools       []ToolHome
+	Project     *config.ProjectConfig
+	ProjectDir  string
+}
+
+// ToolHome is the active install of a runtime defined in AEM_HOME/runtimes.
+type ToolHome struct {
+	Name         string
+	Home         string
+	HomeVariable string
+	BinDirs      []string
+}
+
+type Variable struct {
+	Name  string `json:"name" yaml:"name"`
+	Value string `json:"value" yaml:"value"`
+}
+
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// builtinVariables are set from the built-in runtimes and AEM_HOME.
+var builtinVariables = []string{"AEM_HOME", "AEM_NODE_HOME", "JAVA_HOME", "GRADLE_HOME", "ANDROID_HOME", "ANDROID_SDK_ROOT"}
+
+// CheckToolVariable rejects names that are not valid variable names, PATH,
+// and the variables aem sets for the built-in runtimes.
+func CheckToolVariable(name string) error {
+	if !variableNamePattern.MatchString(name) {
+		return fmt.Errorf("%q is not a valid environment variable name", name)
+	}
+	if strings.EqualFold(name, "PATH") {
+		return fmt.Errorf("PATH cannot be set, list the directories in \"bin\" instead")
+	}
+	for _, builtin := range builtinVariables {
+		if normalizeName(name) == normalizeName(builtin) {
+			return fmt.Errorf("%s is set by aem for a built-in runtime", name)
+		}
+	}
+	return nil
+}
+
+// Variables returns the environment aem manages, in a stable order, with PATH
+// last. Project values may reference ${AEM_NODE_HOME}, ${JAVA_HOME} and any
+// variable from the calling environment.
+func Variables(opts Options, base []string) ([]Variable, error) {
+	baseValues := toMap(base)
+
+	// Runtimes without an active link are left out so an existing
+	// JAVA_HOME, GRADLE_HOME or ANDROID_HOME in the caller environment is not cleared.
+	var vars []Variable
+	for _, v := range []Variable{
+		{Name: "AEM_HOME", Value: opts.AEMHome},
+		{Name: "AEM_NODE_HOME", Value: opts.NodeHome},
+		{Name: "JAVA_HOME", Value: opts.JavaHome},
+		{Name: "GRADLE_HOME", Value: opts.GradleHome},
+		{Name: "ANDROID_HOME", Value: opts.AndroidHome},
+		{Name: "ANDROID_SDK_ROOT", Value: opts.AndroidHome},
+	} {
+		if v.Value != "" {
+			vars = append(vars, v)
+		}
+	}
+	for _, tool := range opts.Tools {
+		if tool.HomeVariable != "" {
+			vars = append(vars, Variable{Name: tool.HomeVariable, Value: tool.Home})
+		}
+	}
+
+	lookup := func(name string) string {
+		for _, v := range vars {
+			if v.Name == name {
+				return v.Value
+			}
+		}
+		return baseValues[name]
+	}
+	expand := func(value string) string {
+		return os.Expand(value, lookup)
+	}
+
+	var pathEntries []string
+	if opts.Project != nil {
+		names := make([]string, 0, len(opts.Project.Env))
+		for name := range opts.Project.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if !variableNamePattern.MatchString(name) {
+				return nil, errors.NewValidationError(fmt.Sprintf("invalid environment variable name %q in project config", name))
+			}
+			if strings.EqualFold(name, "PATH") {
+				return nil, errors.NewValidationError("set PATH entries through the \"path\" list in the project config, not \"env\"")
+			}
+		}
+
+		// Project values are expanded against the aem and caller environment
+		// only, so the result does not depend on map iteration order.
+		var projectVars []Variable
+		for _, name := range names {
+			projectVars = append(projectVars, Variable{Name: name, Value: expand(opts.Project.Env[name])})
+		}
+		vars = setAll(vars, projectVars)
+
+		for _, entry := range opts.Project.Path {
+			entry = expand(strings.TrimSpace(entry))
+			if entry == "" {
+				continue
+			}
+			if !filepath.IsAbs(entry) && opts.ProjectDir != "" {
+				entry = filepath.Join(opts.ProjectDir, entry)
+			}
+			pathEntries = append(pathEntries, filepath.Clean(entry))
+		}
+	}
+
+	pathEntries = append(pathEntries, RuntimeBinDirs(opts)...)
+	pathEntries = append(pathEntries, filepath.SplitList(baseValues[pathVariableName(base)])...)
+	vars = append(vars, Variable{Name: "PATH", Value: joinPath(pathEntries)})
+
+	return vars, nil
+}
+
+// Environ returns base with the aem managed variables applied, suitable for exec.Cmd.Env.
+func Environ(opts Options, base []string) ([]string, error) {
+	vars, err := Variables(opts, base)
+	if err != nil {
+		return nil, err
+	}
+
+	managed := make(map[string]struct{}, len(vars))
+	for _, v := range vars {
+		managed[normalizeName(v.Name)] = struct{}{}
+	}
+
+	env := make([]string, 0, len(base)+len(vars))
+	for _, entry := range base {
+		name, _, _ := strings.Cut(entry, "=")
+		if _, ok := managed[normalizeName(name)]; ok {
+			continue
+		}
+		env = append(env, entry)
+	}
+	for _, v := range vars {
+		env = append(env, v.Name+"="+v.Value)
+	}
+
+	return env, nil
+}
+
+// RuntimeBinDirs returns the bin directories of the active runtimes in the
+// order they should appear on PATH.
+func RuntimeBinDirs(opts Options) []string {
+	var dirs []string
+	if opts.NodeHome != "" {
+		if platform.GetInfo().OS == "windows" {
+			dirs = append(dirs, opts.NodeHome)
+		} else {
+			dirs = append(dirs, filepath.Join(opts.NodeHome, "bin"))
+		}
+	}
+	if opts.JavaHome != "" {
+		dirs = append(dirs, filepath.Join(opts.JavaHome, "bin"))
+	}
+	if opts.GradleHome != "" {
+		dirs = append(dirs, filepath.Join(opts.GradleHome, "bin"))
+	}
+	if opts.RubyHome != "" {
+		dirs = append(dirs, filepath.Join(opts.RubyHome, "bin"))
+	}
+	if opts.PythonHome != "" {
+		if platform.GetInfo().OS == "windows" {
+			dirs = append(dirs, opts.PythonHome, filepath.Join(opts.PythonHome, "Scripts"))
+		} else {
+			dirs = append(dirs, filepath.Join(opts.PythonHome, "bin"))
+		}
+	}
+	for _, tool := range opts.Tools {
+		dirs = append(dirs, tool.BinDirs...)
+	}
+	if opts.AndroidHome != "" {
+		dirs = append(dirs,
+			filepath.Join(opts.AndroidHome, "platform-tools"),
+			filepath.Join(opts.AndroidHome, "cmdline-tools", "latest", "bin"),
+		)
+	}
+	return dirs
+}
+
+func setAll(vars []Variable, updates []Variable) []Variable {
+	for _, update := range updates {
+		replaced := false
+		for i := range vars {
+			if vars[i].Name == update.Name {
+				vars[i].Value = update.Value
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			vars = append(vars, update)
+		}
+	}
+	return vars
+}
+
+func joinPath(entries []string) string {
+	seen := make(map[string]struct{}, len(entries))
+	var unique []string
+	for _, entry := range entries {
+		if entry == "" {
+			continue
+		}
+		key := normalizeName(entry)
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+		unique = append(unique, entry)
+	}
+	return strings.Join(unique, string(os.PathListSeparator))
+}
+
+func toMap(env []string) map[string]string {
+	values := make(map[string]string, len(env))
+	for _, entry := range env {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		values[name] = value
+	}
+	return values
+}
+
+// pathVariableName finds the PATH key as spelled in base, since Windows
+// commonly uses "Path".
+func pathVariableName(base []string) string {
+	for _, entry := range base {
+		name, _, _ := strings.Cut(entry, "=")
+		if normalizeName(name) == normalizeName("PATH") {
+			return name
+		}
+	}
+	return "PATH"
+}
+
+func normalizeName(name string) string {
+	if platform.GetInfo().OS == "windows" {
+		return strings.ToUpper(name)
+	}
+	return name
+}
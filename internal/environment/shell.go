@@ -0,0 +1,60 @@
+package environment
+
+import (
+	"aem/internal/platform"
+	"aem/pkg/errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultShell guesses the syntax `aem env` should print for the caller.
+func DefaultShell() string {
+	if platform.GetInfo().OS == "windows" {
+		return "powershell"
+	}
+	if shell := filepath.Base(os.Getenv("SHELL")); shell == "fish" {
+		return "fish"
+	}
+	return "posix"
+}
+
+// ShellCommand returns the interactive shell `aem shell` should start.
+func ShellCommand() string {
+	if platform.GetInfo().OS == "windows" {
+		if comspec := os.Getenv("COMSPEC"); comspec != "" {
+			return comspec
+		}
+		return "cmd.exe"
+	}
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	return "/bin/sh"
+}
+
+// Format renders vars as statements for the given shell.
+func Format(vars []Variable, shell string) (string, error) {
+	var b strings.Builder
+	for _, v := range vars {
+		switch shell {
+		case "posix", "bash", "zsh", "sh":
+			b.WriteString("export " + v.Name + "='" + strings.ReplaceAll(v.Value, "'", `'\''`) + "'\n")
+		case "fish":
+			value := v.Value
+			if v.Name == "PATH" {
+				value = strings.ReplaceAll(value, string(os.PathListSeparator), "' '")
+			} else {
+				value = strings.ReplaceAll(value, "'", `\'`)
+			}
+			b.WriteString("set -gx " + v.Name + " '" + value + "'\n")
+		case "powershell", "pwsh":
+			b.WriteString("$env:" + v.Name + " = '" + strings.ReplaceAll(v.Value, "'", "''") + "'\n")
+		case "cmd":
+			b.WriteString("set \"" + v.Name + "=" + v.Value + "\"\n")
+		default:
+			return "", errors.NewValidationError("unsupported shell: " + shell + " (expected posix, fish, powershell or cmd)")
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,112 @@
+package gradle
+
+import (
+	"aem/internal/platform"
+	"aem/internal/runtimes"
+	"aem/pkg/downloader"
+	"aem/pkg/errors"
+	"aem/pkg/logger"
+	"aem/pkg/settings"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// Service installs Gradle distributions from services.gradle.org or a mirror
+// of it.
+type Service struct {
+	*runtimes.Service
+}
+
+// Release is an entry of the services.gradle.org versions JSON.
+type Release struct {
+	Version        string `json:"version"`
+	Snapshot       bool   `json:"snapshot"`
+	Nightly        bool   `json:"nightly"`
+	ReleaseNightly bool   `json:"releaseNightly"`
+	Broken         bool   `json:"broken"`
+	RcFor          string `json:"rcFor"`
+	MilestoneFor   string `json:"milestoneFor"`
+}
+
+func NewService(logger *logger.Logger, installDir string) *Service {
+	cfg, err := settings.Load()
+	if err != nil {
+		logger.Debug("Using default Gradle mirror: %v", err)
+	}
+
+	layout := runtimes.Layout{
+		Name:            "gradle",
+		Title:           "Gradle",
+		Archive:         "zip",
+		StripComponents: 1,
+		Probe:           platform.GetInfo().RuntimeBinary("gradle"),
+	}
+	return &Service{Service: runtimes.New(logger, installDir, layout, &source{
+		logger:     logger,
+		downloader: downloader.New(logger),
+		mirror:     cfg.Mirror("gradle"),
+	})}
+}
+
+// source lists the final releases of the versions JSON at the mirror.
+type source struct {
+	logger     *logger.Logger
+	downloader *downloader.Downloader
+	mirror     string
+}
+
+func (s *source) Releases() ([]runtimes.Release, error) {
+	apiURL := s.mirror + "/versions/all"
+	s.logger.Debug("Fetching Gradle versions from: %s", apiURL)
+
+	resp, err := s.downloader.GetHTML(apiURL)
+	if err != nil {
+		return nil, errors.NewAPIError("failed to fetch Gradle versions", err)
+	}
+	defer resp.Close()
+
+	data, err := io.ReadAll(resp)
+	if err != nil {
+		return nil, errors.NewAPIError("failed to read Gradle versions", err)
+	}
+	versions, err := ParseReleases(data)
+	if err != nil {
+		return nil, errors.NewAPIError("failed to parse Gradle versions", err)
+	}
+
+	releases := make([]runtimes.Release, 0, len(versions))
+	for _, version := range versions {
+		releases = append(releases, runtimes.Release{
+			Version: version,
+			URL:     s.mirror + "/distributions/gradle-" + strings.TrimPrefix(version, "v") + "-bin.zip",
+		})
+	}
+	return releases, nil
+}
+
+// ParseReleases returns the final releases of a versions JSON, oldest first,
+// skipping snapshots, nightlies, release candidates, milestones and broken
+// releases.
+func ParseReleases(data []byte) ([]string, error) {
+	var releases []Release
+	if err := json.Unmarshal(data, &releases); err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for _, release := range releases {
+		if release.Snapshot || release.Nightly || release.ReleaseNightly || release.Broken ||
+			release.RcFor != "" || release.MilestoneFor != "" {
+			continue
+		}
+		versions = append(versions, "v"+release.Version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return semver.Compare(versions[i], versions[j]) < 0
+	})
+	return versions, nil
+}
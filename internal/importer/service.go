@@ -0,0 +1,412 @@
+package importer
+
+import (
+	"aem/pkg/errors"
+	"aem/pkg/filesystem"
+	"aem/pkg/logger"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// Install is a runtime installed by another version manager.
+type Install struct {
+	Module  string `json:"module" yaml:"module"`
+	Version string `json:"version" yaml:"version"`
+	Path    string `json:"path" yaml:"path"`
+	Source  string `json:"source" yaml:"source"`
+}
+
+type source struct {
+	name     string
+	discover func(s *Service) []Install
+}
+
+var sources = []source{
+	{name: "nvm", discover: (*Service).discoverNvm},
+	{name: "fnm", discover: (*Service).discoverFnm},
+	{name: "sdkman", discover: (*Service).discoverSdkman},
+	{name: "asdf", discover: (*Service).discoverAsdf},
+	{name: "rbenv", discover: (*Service).discoverRbenv},
+	{name: "pyenv", discover: (*Service).discoverPyenv},
+	{name: "android-studio", discover: (*Service).discoverAndroidStudio},
+}
+
+// SourceNames lists the version managers installs can be imported from.
+func SourceNames() []string {
+	names := make([]string, 0, len(sources))
+	for _, src := range sources {
+		names = append(names, src.name)
+	}
+	return names
+}
+
+var versionPattern = regexp.MustCompile(`[0-9]+(\.[0-9]+)*`)
+
+// linkNamePattern matches the names project configs accept for linked runtimes.
+var linkNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]*$`)
+
+type Service struct {
+	logger     *logger.Logger
+	fs         *filesystem.FileSystem
+	installDir string
+	home       string
+	// lookupEnv reads the variables that relocate other version managers,
+	// such as NVM_DIR or SDKMAN_DIR.
+	lookupEnv func(key string) (string, bool)
+}
+
+func NewService(logger *logger.Logger, installDir string) *Service {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		logger.Debug("Unable to determine home directory: %v", err)
+	}
+
+	return &Service{
+		logger:     logger,
+		fs:         filesystem.New(logger),
+		installDir: installDir,
+		home:       home,
+		lookupEnv:  os.LookupEnv,
+	}
+}
+
+// SetHome overrides the home directory the default locations are resolved
+// against, e.g. to point discovery at a fake directory tree. Variables such
+// as NVM_DIR are ignored from then on, so every location is under home.
+func (s *Service) SetHome(home string) {
+	s.home = home
+	s.lookupEnv = func(string) (string, bool) { return "", false }
+}
+
+// Discover lists the installs found in the locations other version managers
+// use, sorted by module and version. With names, only those sources are read.
+func (s *Service) Discover(names ...string) []Install {
+	var installs []Install
+	if s.home == "" {
+		return installs
+	}
+
+	for _, src := range sources {
+		if len(names) > 0 && !containsString(names, src.name) {
+			continue
+		}
+		found := src.discover(s)
+		s.logger.Debug("Found %d installs from %s", len(found), src.name)
+		installs = append(installs, found...)
+	}
+
+	sort.Slice(installs, func(i, j int) bool {
+		if installs[i].Module != installs[j].Module {
+			return installs[i].Module < installs[j].Module
+		}
+		return semver.Compare("v"+installs[i].Version, "v"+installs[j].Version) < 0
+	})
+	return installs
+}
+
+// Find returns a discovered install of module matching version exactly or as
+// a prefix such as "20".
+func (s *Service) Find(module, version string) (Install, bool) {
+	version = strings.TrimPrefix(version, "v")
+	var best Install
+	found := false
+	for _, install := range s.Discover() {
+		if install.Module != module {
+			continue
+		}
+		if install.Version != version && !strings.HasPrefix(install.Version, version+".") {
+			continue
+		}
+		if !found || semver.Compare("v"+install.Version, "v"+best.Version) > 0 {
+			best = install
+			found = true
+		}
+	}
+	return best, found
+}
+
+// Register links install into sys_installed so that the node and java
+// services can select it like any other version. Existing entries are kept,
+// and the bool reports whether install was newly registered.
+func (s *Service) Register(install Install) (string, bool, error) {
+	target := s.targetPath(install)
+	if _, err := os.Lstat(target); err == nil {
+		return target, false, nil
+	}
+
+	unlock, err := s.fs.Lock()
+	if err != nil {
+		return "", false, err
+	}
+	defer unlock()
+
+	external := filesystem.ExternalRuntime{
+		Name:    filepath.Base(target),
+		Path:    install.Path,
+		Version: install.Version,
+		Source:  install.Source,
+	}
+	if err := s.fs.AddExternal(filepath.Dir(target), external); err != nil {
+		return "", false, errors.NewFileSystemError(fmt.Sprintf("failed to register %s %s from %s", install.Module, install.Version, install.Source), err)
+	}
+
+	s.logger.Debug("Registered %s -> %s", target, install.Path)
+	return target, true, nil
+}
+
+// Copy imports install as a regular aem-managed install, so it survives the
+// other version manager removing it.
+func (s *Service) Copy(install Install) (string, bool, error) {
+	target := s.targetPath(install)
+	if _, err := os.Lstat(target); err == nil {
+		return target, false, nil
+	}
+
+	unlock, err := s.fs.Lock()
+	if err != nil {
+		return "", false, err
+	}
+	defer unlock()
+
+	stagingDir, err := s.fs.NewStagingDir("import")
+	if err != nil {
+		return "", false, err
+	}
+	defer s.fs.RemoveAll(stagingDir)
+
+	staged := filepath.Join(stagingDir, filepath.Base(target))
+	if err := s.fs.CopyDir(install.Path, staged); err != nil {
+		return "", false, err
+	}
+	if err := s.fs.CommitInstall(staged, target); err != nil {
+		return "", false, err
+	}
+	return target, true, nil
+}
+
+// Link registers the runtime at path under name, after checking that it
+// contains the module's binary and that the binary runs.
+func (s *Service) Link(module, name, path string) (filesystem.ExternalRuntime, error) {
+	if !linkNamePattern.MatchString(name) {
+		return filesystem.ExternalRuntime{}, errors.NewValidationError(fmt.Sprintf("invalid name %q: use a letter followed by letters, digits, '.', '_' or '-'", name))
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return filesystem.ExternalRuntime{}, errors.NewFileSystemError("failed to get absolute path", err)
+	}
+	info, err := os.Stat(absPath)
+	if err != nil || !info.IsDir() {
+		return filesystem.ExternalRuntime{}, errors.NewValidationError(absPath + " is not a directory")
+	}
+
+	version, err := DetectVersion(module, absPath)
+	if err != nil {
+		return filesystem.ExternalRuntime{}, err
+	}
+
+	moduleDir := filepath.Join(s.installDir, module)
+	if _, err := os.Lstat(filepath.Join(moduleDir, name)); err == nil {
+		if _, external := s.fs.ExternalName(moduleDir, name); !external {
+			return filesystem.ExternalRuntime{}, errors.NewValidationError(fmt.Sprintf("%s %s is already installed by aem, choose another name", module, name))
+		}
+	}
+
+	external := filesystem.ExternalRuntime{Name: name, Path: absPath, Version: version, Source: "link"}
+	if err := s.fs.AddExternal(moduleDir, external); err != nil {
+		return filesystem.ExternalRuntime{}, err
+	}
+
+	s.logger.Debug("Linked %s %s -> %s", module, name, absPath)
+	return external, nil
+}
+
+// Unlink removes an external runtime registered under name, unless it is the
+// active version of module.
+func (s *Service) Unlink(module, name string) error {
+	st, err := s.fs.GetState()
+	if err != nil {
+		return err
+	}
+	current, err := st.CurrentVersion(module)
+	if err != nil {
+		return err
+	}
+	if current != "" && (current == name || current == strings.TrimPrefix(name, "v")) {
+		return errors.NewValidationError(fmt.Sprintf("cannot unregister %s as it's the currently active %s version", name, module))
+	}
+
+	return s.fs.RemoveExternal(filepath.Join(s.installDir, module), name)
+}
+
+func (s *Service) targetPath(install Install) string {
+	if install.Module == "android" {
+		return filepath.Join(s.installDir, "android", "sdk")
+	}
+	return filepath.Join(s.installDir, install.Module, "v"+install.Version)
+}
+
+// discoverNvm reads ~/.nvm/versions/node/v<version>, or $NVM_DIR, and the
+// flat v<version> layout of nvm-windows in $NVM_HOME.
+func (s *Service) discoverNvm() []Install {
+	root := filepath.Join(s.home, ".nvm")
+	if dir := s.getenv("NVM_DIR"); dir != "" {
+		root = dir
+	}
+
+	installs := listVersionDirs(filepath.Join(root, "versions", "node"), "node", "nvm", nil)
+	if dir := s.getenv("NVM_HOME"); dir != "" {
+		installs = append(installs, listVersionDirs(dir, "node", "nvm", nil)...)
+	}
+	return installs
+}
+
+// discoverFnm reads <fnm dir>/node-versions/v<version>/installation.
+func (s *Service) discoverFnm() []Install {
+	roots := []string{
+		filepath.Join(s.home, ".local", "share", "fnm"),
+		filepath.Join(s.home, "Library", "Application Support", "fnm"),
+		filepath.Join(s.home, ".fnm"),
+	}
+	if dir := s.getenv("APPDATA"); dir != "" {
+		roots = append(roots, filepath.Join(dir, "fnm"))
+	}
+	if dir := s.getenv("FNM_DIR"); dir != "" {
+		roots = []string{dir}
+	}
+
+	var installs []Install
+	for _, root := range roots {
+		for _, install := range listVersionDirs(filepath.Join(root, "node-versions"), "node", "fnm", nil) {
+			install.Path = filepath.Join(install.Path, "installation")
+			if info, err := os.Stat(install.Path); err == nil && info.IsDir() {
+				installs = append(installs, install)
+			}
+		}
+	}
+	return installs
+}
+
+// discoverSdkman reads ~/.sdkman/candidates/java/<version>-<vendor> and
+// candidates/gradle/<version>, or $SDKMAN_DIR.
+func (s *Service) discoverSdkman() []Install {
+	root := filepath.Join(s.home, ".sdkman")
+	if dir := s.getenv("SDKMAN_DIR"); dir != "" {
+		root = dir
+	}
+	installs := listVersionDirs(filepath.Join(root, "candidates", "java"), "java", "sdkman", func(name string) string {
+		if i := strings.Index(name, "-"); i > 0 {
+			return name[:i]
+		}
+		return name
+	})
+	return append(installs, listVersionDirs(filepath.Join(root, "candidates", "gradle"), "gradle", "sdkman", nil)...)
+}
+
+// discoverAsdf reads ~/.asdf/installs/{nodejs,java,ruby,python}/<version>, or
+// $ASDF_DATA_DIR. Java entries carry a vendor prefix such as "zulu-17.0.9".
+func (s *Service) discoverAsdf() []Install {
+	root := filepath.Join(s.home, ".asdf")
+	if dir := s.getenv("ASDF_DATA_DIR"); dir != "" {
+		root = dir
+	}
+
+	installs := listVersionDirs(filepath.Join(root, "installs", "nodejs"), "node", "asdf", nil)
+	installs = append(installs, listVersionDirs(filepath.Join(root, "installs", "java"), "java", "asdf", func(name string) string {
+		return versionPattern.FindString(name)
+	})...)
+	installs = append(installs, listVersionDirs(filepath.Join(root, "installs", "ruby"), "ruby", "asdf", nil)...)
+	return append(installs, listVersionDirs(filepath.Join(root, "installs", "python"), "python", "asdf", nil)...)
+}
+
+// discoverRbenv reads ~/.rbenv/versions/<version>, or $RBENV_ROOT. Builds
+// such as "jruby-9.4.5.0" or "truffleruby-23.1.1" are skipped.
+func (s *Service) discoverRbenv() []Install {
+	root := filepath.Join(s.home, ".rbenv")
+	if dir := s.getenv("RBENV_ROOT"); dir != "" {
+		root = dir
+	}
+	return listVersionDirs(filepath.Join(root, "versions"), "ruby", "rbenv", nil)
+}
+
+// discoverPyenv reads ~/.pyenv/versions/<version>, or $PYENV_ROOT. Builds
+// such as "miniconda3-latest" or "pypy3.10-7.3.17" are skipped.
+func (s *Service) discoverPyenv() []Install {
+	root := filepath.Join(s.home, ".pyenv")
+	if dir := s.getenv("PYENV_ROOT"); dir != "" {
+		root = dir
+	}
+	return listVersionDirs(filepath.Join(root, "versions"), "python", "pyenv", nil)
+}
+
+// discoverAndroidStudio finds the SDK Android Studio installs by default.
+func (s *Service) discoverAndroidStudio() []Install {
+	candidates := []string{
+		filepath.Join(s.home, "Android", "Sdk"),
+		filepath.Join(s.home, "Library", "Android", "sdk"),
+	}
+	if dir := s.getenv("LOCALAPPDATA"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "Android", "Sdk"))
+	}
+
+	var installs []Install
+	for _, candidate := range candidates {
+		if info, err := os.Stat(filepath.Join(candidate, "platform-tools")); err == nil && info.IsDir() {
+			installs = append(installs, Install{Module: "android", Version: "sdk", Path: candidate, Source: "android-studio"})
+		}
+	}
+	return installs
+}
+
+// getenv returns the value of the environment variable key, or "".
+func (s *Service) getenv(key string) string {
+	value, _ := s.lookupEnv(key)
+	return value
+}
+
+// listVersionDirs turns every versioned directory in dir into an Install.
+// parse maps a directory name onto a version; nil strips a leading "v".
+func listVersionDirs(dir, module, sourceName string, parse func(name string) string) []Install {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var installs []Install
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			continue
+		}
+		if entry.Type()&os.ModeSymlink != 0 {
+			// Aliases such as sdkman's "current" point at another entry.
+			continue
+		}
+
+		version := strings.TrimPrefix(entry.Name(), "v")
+		if parse != nil {
+			version = parse(entry.Name())
+		}
+		if version == "" || version[0] < '0' || version[0] > '9' {
+			continue
+		}
+
+		installs = append(installs, Install{Module: module, Version: version, Path: path, Source: sourceName})
+	}
+	return installs
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,262 @@
+package importer
+
+import (
+	"aem/internal/android"
+	"aem/internal/config"
+	"aem/pkg/logger"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestService returns a service with AEM_HOME, the home directory and
+// the variables of env under root. Paths in env are relative to root.
+func newTestService(t *testing.T, root string, env map[string]string) *Service {
+	t.Helper()
+
+	t.Setenv("AEM_HOME", filepath.Join(root, "aem"))
+	s := NewService(logger.New(false), filepath.Join(root, "aem", "sys_installed"))
+	s.home = filepath.Join(root, "home")
+	s.lookupEnv = func(key string) (string, bool) {
+		value, ok := env[key]
+		if !ok {
+			return "", false
+		}
+		return filepath.Join(root, value), true
+	}
+	return s
+}
+
+// makeTree creates the directories under root, and a symlink for every
+// "link -> target" entry.
+func makeTree(t *testing.T, root string, dirs []string, links map[string]string) {
+	t.Helper()
+
+	for _, dir := range dirs {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range links {
+		if err := os.Symlink(filepath.Join(root, target), filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		dirs   []string
+		links  map[string]string
+		env    map[string]string
+		// want holds installs with paths relative to the test root
+		want []Install
+	}{
+		{
+			name:   "nvm",
+			source: "nvm",
+			dirs:   []string{"home/.nvm/versions/node/v20.11.0", "home/.nvm/versions/node/v18.19.0", "home/.nvm/versions/node/system"},
+			want: []Install{
+				{Module: "node", Version: "18.19.0", Path: "home/.nvm/versions/node/v18.19.0", Source: "nvm"},
+				{Module: "node", Version: "20.11.0", Path: "home/.nvm/versions/node/v20.11.0", Source: "nvm"},
+			},
+		},
+		{
+			name:   "nvm in NVM_DIR",
+			source: "nvm",
+			dirs:   []string{"home/.nvm/versions/node/v20.11.0", "nvm/versions/node/v22.1.0"},
+			env:    map[string]string{"NVM_DIR": "nvm"},
+			want:   []Install{{Module: "node", Version: "22.1.0", Path: "nvm/versions/node/v22.1.0", Source: "nvm"}},
+		},
+		{
+			name:   "fnm",
+			source: "fnm",
+			dirs:   []string{"home/.local/share/fnm/node-versions/v20.11.0/installation", "home/.local/share/fnm/node-versions/v19.0.0"},
+			want:   []Install{{Module: "node", Version: "20.11.0", Path: "home/.local/share/fnm/node-versions/v20.11.0/installation", Source: "fnm"}},
+		},
+		{
+			name:   "fnm in APPDATA",
+			source: "fnm",
+			dirs:   []string{"AppData/Roaming/fnm/node-versions/v20.11.0/installation"},
+			env:    map[string]string{"APPDATA": "AppData/Roaming"},
+			want:   []Install{{Module: "node", Version: "20.11.0", Path: "AppData/Roaming/fnm/node-versions/v20.11.0/installation", Source: "fnm"}},
+		},
+		{
+			name:   "fnm in FNM_DIR",
+			source: "fnm",
+			dirs:   []string{"home/.fnm/node-versions/v18.19.0/installation", "fnm/node-versions/v21.6.0/installation"},
+			env:    map[string]string{"FNM_DIR": "fnm"},
+			want:   []Install{{Module: "node", Version: "21.6.0", Path: "fnm/node-versions/v21.6.0/installation", Source: "fnm"}},
+		},
+		{
+			name:   "sdkman",
+			source: "sdkman",
+			dirs:   []string{"home/.sdkman/candidates/java/17.0.9-tem", "home/.sdkman/candidates/gradle/8.5"},
+			links:  map[string]string{"home/.sdkman/candidates/java/current": "home/.sdkman/candidates/java/17.0.9-tem"},
+			want: []Install{
+				{Module: "gradle", Version: "8.5", Path: "home/.sdkman/candidates/gradle/8.5", Source: "sdkman"},
+				{Module: "java", Version: "17.0.9", Path: "home/.sdkman/candidates/java/17.0.9-tem", Source: "sdkman"},
+			},
+		},
+		{
+			name:   "sdkman in SDKMAN_DIR",
+			source: "sdkman",
+			dirs:   []string{"sdkman/candidates/java/21.0.2-zulu"},
+			env:    map[string]string{"SDKMAN_DIR": "sdkman"},
+			want:   []Install{{Module: "java", Version: "21.0.2", Path: "sdkman/candidates/java/21.0.2-zulu", Source: "sdkman"}},
+		},
+		{
+			name:   "asdf",
+			source: "asdf",
+			dirs: []string{
+				"home/.asdf/installs/nodejs/20.11.0",
+				"home/.asdf/installs/java/zulu-17.0.9",
+				"home/.asdf/installs/ruby/3.3.0",
+				"home/.asdf/installs/python/3.12.1",
+				"home/.asdf/installs/python/miniconda3-latest",
+			},
+			want: []Install{
+				{Module: "java", Version: "17.0.9", Path: "home/.asdf/installs/java/zulu-17.0.9", Source: "asdf"},
+				{Module: "node", Version: "20.11.0", Path: "home/.asdf/installs/nodejs/20.11.0", Source: "asdf"},
+				{Module: "python", Version: "3.12.1", Path: "home/.asdf/installs/python/3.12.1", Source: "asdf"},
+				{Module: "ruby", Version: "3.3.0", Path: "home/.asdf/installs/ruby/3.3.0", Source: "asdf"},
+			},
+		},
+		{
+			name:   "asdf in ASDF_DATA_DIR",
+			source: "asdf",
+			dirs:   []string{"asdf/installs/nodejs/18.19.0"},
+			env:    map[string]string{"ASDF_DATA_DIR": "asdf"},
+			want:   []Install{{Module: "node", Version: "18.19.0", Path: "asdf/installs/nodejs/18.19.0", Source: "asdf"}},
+		},
+		{
+			name:   "android studio",
+			source: "android-studio",
+			dirs:   []string{"home/Android/Sdk/platform-tools", "home/Library/Android/sdk"},
+			want:   []Install{{Module: "android", Version: "sdk", Path: "home/Android/Sdk", Source: "android-studio"}},
+		},
+		{
+			name:   "android studio in LOCALAPPDATA",
+			source: "android-studio",
+			dirs:   []string{"AppData/Local/Android/Sdk/platform-tools"},
+			env:    map[string]string{"LOCALAPPDATA": "AppData/Local"},
+			want:   []Install{{Module: "android", Version: "sdk", Path: "AppData/Local/Android/Sdk", Source: "android-studio"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			makeTree(t, root, tt.dirs, tt.links)
+			s := newTestService(t, root, tt.env)
+
+			want := []Install{}
+			for _, install := range tt.want {
+				install.Path = filepath.Join(root, install.Path)
+				want = append(want, install)
+			}
+			got := append([]Install{}, s.Discover(tt.source)...)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Discover(%q) = %+v, want %+v", tt.source, got, want)
+			}
+		})
+	}
+}
+
+func TestSetHomeIgnoresEnvironment(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, []string{"nvm/versions/node/v22.1.0", "other/.nvm/versions/node/v20.11.0"}, nil)
+	s := newTestService(t, root, map[string]string{"NVM_DIR": "nvm"})
+	s.SetHome(filepath.Join(root, "other"))
+
+	want := []Install{{Module: "node", Version: "20.11.0", Path: filepath.Join(root, "other/.nvm/versions/node/v20.11.0"), Source: "nvm"}}
+	if got := s.Discover("nvm"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Discover() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		dirs   []string
+		// target is relative to sys_installed
+		target string
+	}{
+		{name: "node from nvm", source: "nvm", dirs: []string{"home/.nvm/versions/node/v20.11.0/bin"}, target: "node/v20.11.0"},
+		{name: "java from sdkman", source: "sdkman", dirs: []string{"home/.sdkman/candidates/java/17.0.9-tem/bin"}, target: "java/v17.0.9"},
+		{name: "android studio sdk", source: "android-studio", dirs: []string{"home/Android/Sdk/platform-tools"}, target: "android/sdk"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			makeTree(t, root, tt.dirs, nil)
+			s := newTestService(t, root, nil)
+
+			installs := s.Discover(tt.source)
+			if len(installs) != 1 {
+				t.Fatalf("Discover(%q) = %+v, want one install", tt.source, installs)
+			}
+			install := installs[0]
+
+			target, added, err := s.Register(install)
+			if err != nil {
+				t.Fatalf("Register() error = %v", err)
+			}
+			if want := filepath.Join(s.installDir, tt.target); target != want || !added {
+				t.Fatalf("Register() = %q, %v, want %q, true", target, added, want)
+			}
+			if resolved, err := filepath.EvalSymlinks(target); err != nil || resolved != install.Path {
+				t.Errorf("%s resolves to %q (%v), want %q", target, resolved, err, install.Path)
+			}
+
+			external, err := s.fs.ExternalRuntimes(filepath.Dir(target))
+			if err != nil {
+				t.Fatalf("ExternalRuntimes() error = %v", err)
+			}
+			if runtime := external[filepath.Base(target)]; runtime.Path != install.Path || runtime.Source != tt.source {
+				t.Errorf("external runtime = %+v, want path %q from %s", runtime, install.Path, tt.source)
+			}
+
+			if _, added, err := s.Register(install); err != nil || added {
+				t.Errorf("second Register() = %v, %v, want an existing entry", added, err)
+			}
+		})
+	}
+}
+
+func TestRegisteredAndroidSDKIsExternal(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, []string{"home/Android/Sdk/platform-tools"}, nil)
+	s := newTestService(t, root, nil)
+
+	for _, install := range s.Discover("android-studio") {
+		if _, _, err := s.Register(install); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+	}
+
+	service := android.NewService(logger.New(false), s.installDir)
+	sdk, ok := service.ExternalSDK()
+	if !ok || sdk.Path != filepath.Join(root, "home/Android/Sdk") {
+		t.Fatalf("ExternalSDK() = %+v, %v, want the Android Studio SDK", sdk, ok)
+	}
+	if err := service.AcceptLicenses(""); err == nil {
+		t.Error("AcceptLicenses() on an imported SDK succeeded")
+	}
+
+	// Setup only checks the packages of an imported SDK
+	cfg := config.AndroidConfig{SDK: config.StringList{"34"}}
+	if err := service.Setup(cfg, ""); err == nil || !strings.Contains(err.Error(), "missing platforms;android-34") {
+		t.Errorf("Setup() with a missing package error = %v", err)
+	}
+	makeTree(t, root, []string{"home/Android/Sdk/platforms/android-34"}, nil)
+	if err := service.Setup(cfg, ""); err != nil {
+		t.Errorf("Setup() with the packages installed error = %v", err)
+	}
+}
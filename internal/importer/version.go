@@ -0,0 +1,105 @@
+package importer
+
+import (
+	"aem/internal/platform"
+	"aem/pkg/errors"
+	"aem/pkg/process"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const versionCommandTimeout = 15 * time.Second
+
+var javaVersionPattern = regexp.MustCompile(`version "([^"]+)"`)
+
+var rubyVersionPattern = regexp.MustCompile(`^ruby ([0-9]+\.[0-9]+\.[0-9]+)`)
+
+var pythonVersionPattern = regexp.MustCompile(`^Python ([0-9]+\.[0-9]+\.[0-9]+)`)
+
+var gradleVersionPattern = regexp.MustCompile(`^gradle-launcher-([0-9][0-9A-Za-z.-]*)\.jar$`)
+
+// DetectVersion runs the module's binary under home and returns the version it
+// reports, in the form used for install directories.
+func DetectVersion(module, home string) (string, error) {
+	binary := filepath.Join(home, platform.GetInfo().RuntimeBinary(module))
+	if _, err := os.Stat(binary); err != nil {
+		return "", errors.NewValidationError(fmt.Sprintf("%s not found, expected a %s installation in %s", binary, module, home))
+	}
+
+	switch module {
+	case "node":
+		output, err := runVersionCommand(binary, "--version")
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimPrefix(strings.TrimSpace(output), "v"), nil
+	case "java":
+		output, err := runVersionCommand(binary, "-version")
+		if err != nil {
+			return "", err
+		}
+		match := javaVersionPattern.FindStringSubmatch(output)
+		if match == nil {
+			return "", errors.NewValidationError("unrecognised output of java -version: " + strings.TrimSpace(output))
+		}
+		return normalizeJavaVersion(match[1]), nil
+	case "gradle":
+		// Running gradle needs a JDK, the launcher jar carries the version too
+		entries, err := os.ReadDir(filepath.Join(home, "lib"))
+		if err != nil {
+			return "", errors.NewValidationError(fmt.Sprintf("%s is not a Gradle distribution: %v", home, err))
+		}
+		for _, entry := range entries {
+			if match := gradleVersionPattern.FindStringSubmatch(entry.Name()); match != nil {
+				return match[1], nil
+			}
+		}
+		return "", errors.NewValidationError(fmt.Sprintf("%s is not a Gradle distribution: no lib/gradle-launcher jar", home))
+	case "ruby":
+		output, err := runVersionCommand(binary, "--version")
+		if err != nil {
+			return "", err
+		}
+		match := rubyVersionPattern.FindStringSubmatch(strings.TrimSpace(output))
+		if match == nil {
+			return "", errors.NewValidationError("unrecognised output of ruby --version: " + strings.TrimSpace(output))
+		}
+		return match[1], nil
+	case "python":
+		output, err := runVersionCommand(binary, "--version")
+		if err != nil {
+			return "", err
+		}
+		match := pythonVersionPattern.FindStringSubmatch(strings.TrimSpace(output))
+		if match == nil {
+			return "", errors.NewValidationError("unrecognised output of python3 --version: " + strings.TrimSpace(output))
+		}
+		return match[1], nil
+	default:
+		return "", errors.NewValidationError(fmt.Sprintf("cannot detect the version of %s", module))
+	}
+}
+
+func runVersionCommand(binary string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(process.Context(), versionCommandTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, binary, args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s %s failed: %w", binary, strings.Join(args, " "), err)
+	}
+	return string(output), nil
+}
+
+// normalizeJavaVersion maps legacy "1.8.0_392" versions onto the "8.0.392"
+// form used for install directories.
+func normalizeJavaVersion(version string) string {
+	version = strings.TrimPrefix(version, "1.")
+	return strings.ReplaceAll(version, "_", ".")
+}
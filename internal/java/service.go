@@ -7,12 +7,14 @@ import (
 	"aem/pkg/errors"
 	"aem/pkg/filesystem"
 	"aem/pkg/logger"
+	"aem/pkg/settings"
 	"encoding/json"
 	"fmt"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/mod/semver"
 )
@@ -23,6 +25,7 @@ type Service struct {
 	fs         *filesystem.FileSystem
 	extractor  *archiver.ZipExtractor
 	installDir string
+	mirror     string
 }
 
 type AzulPackage struct {
@@ -32,21 +35,32 @@ type AzulPackage struct {
 }
 
 func NewService(logger *logger.Logger, installDir string) *Service {
+	cfg, err := settings.Load()
+	if err != nil {
+		logger.Debug("Using default JDK mirror: %v", err)
+	}
+
 	return &Service{
 		logger:     logger,
 		downloader: downloader.New(logger),
 		fs:         filesystem.New(logger),
 		extractor:  archiver.NewZipExtractor(logger),
 		installDir: installDir,
+		mirror:     cfg.Mirror("java"),
 	}
 }
 
 func (s *Service) Install(majorVersion string) (string, error) {
 	s.logger.Debug("Installing JDK version: %s", majorVersion)
 
+	// Runtimes linked with 'aem link' are selected by name
+	if name, ok := s.fs.ExternalName(filepath.Join(s.installDir, "java"), majorVersion); ok {
+		return name, nil
+	}
+
 	// Check if already installed
 	versionPath := filepath.Join(s.installDir, "java", "v"+majorVersion)
-	if s.fs.Exists(versionPath) {
+	if s.fs.InstallComplete(versionPath, platform.GetInfo().RuntimeBinary("java")) {
 		s.logger.Debug("JDK version %s already installed", majorVersion)
 		return "v" + majorVersion, nil
 	}
@@ -54,21 +68,27 @@ func (s *Service) Install(majorVersion string) (string, error) {
 	// Get platform info
 	platform := platform.GetInfo()
 
-	// Fetch available packages
-	packages, err := s.fetchPackages(majorVersion, platform)
+	pkg, err := s.newestPackage(majorVersion, platform)
 	if err != nil {
 		return "", err
 	}
 
-	if len(packages) == 0 {
-		return "", errors.NewValidationError("no JDK packages found for version " + majorVersion)
+	// Resolved before locking, so a slow mirror does not hold up other aem
+	// processes
+	unlock, err := s.fs.Lock()
+	if err != nil {
+		return "", err
 	}
-
-	pkg := packages[0]
+	defer unlock()
 
 	// Create version string
 	versionStr := s.createVersionString(pkg.JavaVersion)
 	finalPath := filepath.Join(s.installDir, "java", versionStr)
+	if s.fs.InstallComplete(finalPath, platform.RuntimeBinary("java")) {
+		s.fs.MarkInstallComplete(finalPath)
+		s.logger.Debug("JDK version %s already installed", versionStr)
+		return versionStr, nil
+	}
 
 	// Download and install
 	if err := s.downloadAndInstall(pkg, finalPath); err != nil {
@@ -82,6 +102,10 @@ func (s *Service) Install(majorVersion string) (string, error) {
 func (s *Service) Use(version string, symlinkPath string) error {
 	s.logger.Debug("Setting JDK version: %s", version)
 
+	if name, ok := s.fs.ExternalName(filepath.Join(s.installDir, "java"), version); ok {
+		version = name
+	}
+
 	versionPath := filepath.Join(s.installDir, "java", version)
 	if !s.fs.Exists(versionPath) {
 		return errors.NewValidationError("JDK version not installed: " + version)
@@ -105,7 +129,8 @@ func (s *Service) List() ([]string, error) {
 		return nil, err
 	}
 
-	entries, err := s.fs.ListDir(javaPath)
+	// Includes JDKs registered with 'aem link' or 'aem import'
+	installed, err := s.fs.InstalledVersions(javaPath)
 	if err != nil {
 		return nil, err
 	}
@@ -124,13 +149,6 @@ func (s *Service) List() ([]string, error) {
 		}
 	}
 
-	var installed []string
-	for _, entry := range entries {
-		if entry.IsDir() {
-			installed = append(installed, entry.Name())
-		}
-	}
-
 	sort.Slice(installed, func(i, j int) bool {
 		left := installed[i]
 		right := installed[j]
@@ -153,10 +171,73 @@ func (s *Service) List() ([]string, error) {
 	return versions, nil
 }
 
+// Resolve returns the newest remote release matching version, e.g. "v17.0.13"
+// for "17".
+func (s *Service) Resolve(version string) (string, error) {
+	pkg, err := s.newestPackage(strings.TrimPrefix(version, "v"), platform.GetInfo())
+	if err != nil {
+		return "", err
+	}
+	return s.createVersionString(pkg.JavaVersion), nil
+}
+
+// LatestLTS returns the newest release of the most recent LTS line.
+func (s *Service) LatestLTS() (string, error) {
+	for major := latestLTSMajor(time.Now()); major >= 17; major -= 4 {
+		version, err := s.Resolve(strconv.Itoa(major))
+		if err == nil {
+			return version, nil
+		}
+		// The mirror may not carry the newest line yet
+		s.logger.Debug("No JDK %d packages: %v", major, err)
+	}
+	return "", errors.NewAPIError("no LTS JDK release found", nil)
+}
+
+// latestLTSMajor returns the newest LTS feature release expected at now. LTS
+// releases ship every September of odd years since JDK 17 in 2021.
+func latestLTSMajor(now time.Time) int {
+	years := now.Year() - 2021
+	if years%2 == 1 || now.Month() < time.September {
+		years--
+	}
+	if years < 0 {
+		return 17
+	}
+	return 17 + 4*(years/2)
+}
+
+func (s *Service) newestPackage(version string, platform platform.Info) (AzulPackage, error) {
+	packages, err := s.fetchPackages(version, platform)
+	if err != nil {
+		return AzulPackage{}, err
+	}
+	if len(packages) == 0 {
+		return AzulPackage{}, errors.NewValidationError("no JDK packages found for version " + version)
+	}
+
+	newest := packages[0]
+	for _, pkg := range packages[1:] {
+		if compareJavaVersions(pkg.JavaVersion, newest.JavaVersion) > 0 {
+			newest = pkg
+		}
+	}
+	return newest, nil
+}
+
+func compareJavaVersions(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return len(a) - len(b)
+}
+
 func (s *Service) fetchPackages(javaVersion string, platform platform.Info) ([]AzulPackage, error) {
 	apiURL := fmt.Sprintf(
-		"https://api.azul.com/metadata/v1/zulu/packages/?java_version=%s&arch=%s&os=%s&archive_type=zip&java_package_type=jdk",
-		javaVersion, platform.MapArchitecture(), platform.OS,
+		"%s/?java_version=%s&arch=%s&os=%s&archive_type=zip&java_package_type=jdk",
+		s.mirror, javaVersion, platform.MapArchitecture(), platform.OS,
 	)
 
 	s.logger.Debug("Fetching JDK packages from: %s", apiURL)
@@ -176,23 +257,14 @@ func (s *Service) fetchPackages(javaVersion string, platform platform.Info) ([]A
 }
 
 func (s *Service) downloadAndInstall(pkg AzulPackage, finalPath string) error {
-	// Get temp directory from AEM_HOME
-	tmpDir, err := s.fs.GetTempDir()
+	stagingDir, err := s.fs.NewStagingDir("java")
 	if err != nil {
 		return err
 	}
+	defer s.fs.RemoveAll(stagingDir)
 
-	zipPath := filepath.Join(tmpDir, pkg.Name)
-	extractDir := filepath.Join(tmpDir, "jdk_extract")
-
-	// Ensure cleanup
-	defer func() {
-		s.fs.RemoveAll(zipPath)
-		s.fs.RemoveAll(extractDir)
-	}()
-
-	s.fs.RemoveAll(zipPath)
-	s.fs.RemoveAll(extractDir)
+	zipPath := filepath.Join(stagingDir, pkg.Name)
+	extractDir := filepath.Join(stagingDir, "extract")
 
 	// Download
 	if err := s.downloader.Download(pkg.DownloadURL, zipPath); err != nil {
@@ -214,16 +286,8 @@ func (s *Service) downloadAndInstall(pkg AzulPackage, finalPath string) error {
 		return errors.NewExtractionError("expected single root directory in JDK archive", nil)
 	}
 
-	extractedRoot := filepath.Join(extractDir, entries[0].Name())
-
-	// Ensure destination directory exists
-	if err := s.fs.EnsureDir(filepath.Dir(finalPath)); err != nil {
-		return err
-	}
-
 	// Move to final location
-	s.fs.RemoveAll(finalPath) // Remove if exists
-	return s.fs.Move(extractedRoot, finalPath)
+	return s.fs.CommitInstall(filepath.Join(extractDir, entries[0].Name()), finalPath)
 }
 
 func (s *Service) createVersionString(javaVersion []int) string {
@@ -242,17 +306,33 @@ func (s *Service) GetCurrentJDKVersion() (string, error) {
 	return state.CurrentJavaVersion()
 }
 
+func (s *Service) CanUninstall(version string) error {
+	currentVersion, err := s.GetCurrentJDKVersion()
+	if err != nil {
+		return err
+	}
+
+	if currentVersion != "" && currentVersion == strings.TrimPrefix(version, "v") {
+		return errors.UninstallError(fmt.Sprintf("cannot uninstall version %s as it's the currently active version", version), nil)
+	}
+
+	if name, ok := s.fs.ExternalName(filepath.Join(s.installDir, "java"), version); ok {
+		return errors.UninstallError(fmt.Sprintf("cannot uninstall %s as it's an external runtime, run 'aem link --remove java %s' to unregister it", name, name), nil)
+	}
+	return nil
+}
+
 func (s *Service) Uninstall(majorVersion string) error {
 	s.logger.Debug("Un-installing JDK version: %s", majorVersion)
 
-	// Check if the environment is being set
-	currentVersion, err := s.GetCurrentJDKVersion()
+	unlock, err := s.fs.Lock()
 	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	if currentVersion == majorVersion || currentVersion == "v"+majorVersion {
-		return errors.UninstallError(fmt.Sprintf("cannot uninstall version %s as it's the currently active version", majorVersion), nil)
+	if err := s.CanUninstall(majorVersion); err != nil {
+		return err
 	}
 
 	// Check if already installed
@@ -0,0 +1,198 @@
+package node
+
+import (
+	"aem/internal/platform"
+	"aem/pkg/errors"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// bundledPackages ship with every Node install and are never reinstalled.
+var bundledPackages = map[string]bool{
+	"npm":      true,
+	"corepack": true,
+}
+
+// AddGlobalPackages adds a project's packages to the default ones Install
+// provisions; a project entry replaces a default entry for the same package.
+func (s *Service) AddGlobalPackages(project []string) {
+	merged := append([]string{}, project...)
+	for _, pkg := range s.globalPackages {
+		overridden := false
+		for _, override := range project {
+			if PackageName(override) == PackageName(pkg) {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			merged = append(merged, pkg)
+		}
+	}
+	s.globalPackages = merged
+}
+
+// InstallGlobalPackages installs the npm packages into the Node install
+// selected by version, skipping those it already has in a matching version.
+// Linked runtimes are left untouched.
+func (s *Service) InstallGlobalPackages(version string, packages []string) error {
+	if len(packages) == 0 {
+		return nil
+	}
+	if name, ok := s.fs.ExternalName(filepath.Join(s.installDir, "node"), version); ok {
+		s.logger.Debug("Not installing global packages into linked runtime %s", name)
+		return nil
+	}
+
+	nodeHome, err := s.installPath(version)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, pkg := range packages {
+		installed := installedPackageVersion(nodeHome, PackageName(pkg))
+		if installed == "" || !versionSatisfies(installed, packageVersion(pkg)) {
+			missing = append(missing, pkg)
+		}
+	}
+	if len(missing) == 0 {
+		s.logger.Debug("Global packages already installed in %s", nodeHome)
+		return nil
+	}
+
+	unlock, err := s.fs.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s.logger.Info("Installing global packages into Node.js %s: %s", version, strings.Join(missing, ", "))
+	args := append([]string{"install", "--global", "--prefix", nodeHome}, missing...)
+	if err := s.runNodeTool(nodeBinDir(nodeHome), "npm", args...); err != nil {
+		return errors.NewDownloadError("failed to install global packages", err)
+	}
+	return nil
+}
+
+// GlobalPackages returns the names of the packages installed globally into
+// the Node install selected by version, without the ones bundled with Node.
+func (s *Service) GlobalPackages(version string) ([]string, error) {
+	if name, ok := s.fs.ExternalName(filepath.Join(s.installDir, "node"), version); ok {
+		version = name
+	}
+	nodeHome, err := s.installPath(version)
+	if err != nil {
+		return nil, err
+	}
+
+	modulesDir := globalModulesDir(nodeHome)
+	entries, err := os.ReadDir(modulesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.NewFileSystemError("failed to read "+modulesDir, err)
+	}
+
+	var packages []string
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case strings.HasPrefix(name, "."), bundledPackages[name]:
+			continue
+		case strings.HasPrefix(name, "@"):
+			scoped, err := os.ReadDir(filepath.Join(modulesDir, name))
+			if err != nil {
+				return nil, errors.NewFileSystemError("failed to read "+filepath.Join(modulesDir, name), err)
+			}
+			for _, child := range scoped {
+				packages = append(packages, name+"/"+child.Name())
+			}
+		default:
+			packages = append(packages, name)
+		}
+	}
+	sort.Strings(packages)
+	return packages, nil
+}
+
+// PackageName strips the version or tag from an npm package spec such as
+// "@expo/ngrok@4".
+func PackageName(spec string) string {
+	if at := strings.LastIndex(spec, "@"); at > 0 {
+		return spec[:at]
+	}
+	return spec
+}
+
+// packageVersion returns the version or tag of an npm package spec, "" when
+// it has none.
+func packageVersion(spec string) string {
+	if at := strings.LastIndex(spec, "@"); at > 0 {
+		return spec[at+1:]
+	}
+	return ""
+}
+
+// installedPackageVersion reads the version of a globally installed package,
+// "" when it is not installed.
+func installedPackageVersion(nodeHome, name string) string {
+	data, err := os.ReadFile(filepath.Join(globalModulesDir(nodeHome), name, "package.json"))
+	if err != nil {
+		return ""
+	}
+	var manifest struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest.Version == "" {
+		// Installed, but with no readable version to compare
+		return "unknown"
+	}
+	return manifest.Version
+}
+
+var versionRangePattern = regexp.MustCompile(`^([~^]?)v?([0-9]+(?:\.[0-9]+){0,2})(?:\.[x*])?$`)
+
+// versionSatisfies reports whether installed matches spec, which may be empty,
+// a version prefix such as "5" or "5.1", an exact version, or a ^ or ~ range.
+// Tags and other ranges cannot be checked offline, so they never match and npm
+// resolves them again.
+func versionSatisfies(installed, spec string) bool {
+	if spec == "" || spec == "*" {
+		return true
+	}
+	match := versionRangePattern.FindStringSubmatch(spec)
+	if match == nil || !semver.IsValid("v"+installed) {
+		return false
+	}
+
+	current := "v" + installed
+	base := "v" + match[2]
+	switch match[1] {
+	case "^":
+		// Same left-most non-zero component, like npm
+		limit := semver.Major(base)
+		if limit == "v0" {
+			limit = semver.MajorMinor(base)
+		}
+		return semver.Compare(current, base) >= 0 && strings.HasPrefix(current+".", limit+".")
+	case "~":
+		return semver.Compare(current, base) >= 0 && strings.HasPrefix(current+".", semver.MajorMinor(base)+".")
+	default:
+		return current == base || strings.HasPrefix(current, base+".")
+	}
+}
+
+func globalModulesDir(nodeHome string) string {
+	if platform.GetInfo().OS == "windows" {
+		return filepath.Join(nodeHome, "node_modules")
+	}
+	return filepath.Join(nodeHome, "lib", "node_modules")
+}
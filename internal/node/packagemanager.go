@@ -0,0 +1,157 @@
+package node
+
+import (
+	"aem/internal/platform"
+	"aem/pkg/errors"
+	"aem/pkg/process"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// packageManagerMarker records, inside a Node install, the package manager
+// spec last provisioned into it.
+const packageManagerMarker = ".aem-package-manager"
+
+var packageManagerPattern = regexp.MustCompile(`^(npm|yarn|pnpm)@([0-9]+(?:\.[0-9]+){0,2}(?:-[0-9A-Za-z.-]+)?)(\+.+)?$`)
+
+// PackageManager is a package manager spec in the form of package.json's
+// "packageManager" field, e.g. "yarn@3.6.4+sha512.…".
+type PackageManager struct {
+	Name    string
+	Version string
+	Spec    string
+}
+
+func ParsePackageManager(spec string) (PackageManager, error) {
+	match := packageManagerPattern.FindStringSubmatch(strings.TrimSpace(spec))
+	if match == nil {
+		return PackageManager{}, errors.NewValidationError(fmt.Sprintf("invalid packageManager %q: expected npm, yarn or pnpm followed by @<version>", spec))
+	}
+	return PackageManager{Name: match[1], Version: match[2], Spec: match[0]}, nil
+}
+
+// InstallPackageManager provisions pm into the Node install selected by
+// version: through corepack when the install ships it, or with npm otherwise.
+// Linked runtimes are left untouched.
+func (s *Service) InstallPackageManager(version string, pm PackageManager) error {
+	if name, ok := s.fs.ExternalName(filepath.Join(s.installDir, "node"), version); ok {
+		s.logger.Info("Not installing %s into linked runtime %s, aem does not modify it", pm.Spec, name)
+		return nil
+	}
+
+	nodeHome, err := s.installPath(version)
+	if err != nil {
+		return err
+	}
+	binDir := nodeBinDir(nodeHome)
+
+	if InstalledPackageManager(nodeHome) == pm.Spec && s.fs.Exists(filepath.Join(binDir, executableName(pm.Name))) {
+		s.logger.Debug("%s already provisioned in %s", pm.Spec, nodeHome)
+		return nil
+	}
+
+	unlock, err := s.fs.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s.logger.Info("Installing %s into Node.js %s", pm.Spec, version)
+	corepack := filepath.Join(binDir, executableName("corepack"))
+	switch {
+	case pm.Name == "npm":
+		err = s.runNodeTool(binDir, "npm", "install", "--global", "--prefix", nodeHome, "npm@"+pm.Version)
+	case s.fs.Exists(corepack):
+		err = s.runNodeTool(binDir, "corepack", "enable", "--install-directory", binDir, pm.Name)
+		if err == nil {
+			// "corepack install" replaced "prepare" in corepack 0.20
+			if err = s.runNodeTool(binDir, "corepack", "install", "--global", pm.Spec); err != nil {
+				s.logger.Debug("corepack install failed, trying prepare: %v", err)
+				err = s.runNodeTool(binDir, "corepack", "prepare", pm.Spec, "--activate")
+			}
+		}
+	default:
+		err = s.runNodeTool(binDir, "npm", "install", "--global", "--prefix", nodeHome, npmPackageFor(pm))
+	}
+	if err != nil {
+		return errors.NewDownloadError("failed to install "+pm.Spec, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(nodeHome, packageManagerMarker), []byte(pm.Spec+"\n"), 0644); err != nil {
+		return errors.FileWriteSystemError("failed to record package manager", err)
+	}
+	return nil
+}
+
+// InstalledPackageManager returns the package manager spec last provisioned
+// into the Node install at nodeHome, or "".
+func InstalledPackageManager(nodeHome string) string {
+	data, err := os.ReadFile(filepath.Join(nodeHome, packageManagerMarker))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func (s *Service) installPath(version string) (string, error) {
+	for _, name := range []string{version, "v" + version} {
+		path := filepath.Join(s.installDir, "node", name)
+		if s.fs.Exists(path) {
+			return path, nil
+		}
+	}
+	return "", errors.NewValidationError("Node.js version not installed: " + version)
+}
+
+// runNodeTool runs one of the scripts in binDir with that Node first on PATH,
+// as the scripts start node through env.
+func (s *Service) runNodeTool(binDir, tool string, args ...string) error {
+	path := filepath.Join(binDir, executableName(tool))
+	var cmd *exec.Cmd
+	if platform.GetInfo().OS == "windows" {
+		cmd = exec.CommandContext(process.Context(), "cmd", append([]string{"/c", path}, args...)...)
+	} else {
+		cmd = exec.CommandContext(process.Context(), path, args...)
+	}
+	cmd.Env = append(os.Environ(),
+		"PATH="+binDir+string(os.PathListSeparator)+os.Getenv("PATH"),
+		"COREPACK_ENABLE_DOWNLOAD_PROMPT=0",
+	)
+
+	s.logger.Debug("Running %s %s", tool, strings.Join(args, " "))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %s", tool, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// npmPackageFor returns the npm package providing pm. Yarn 2 and later are
+// only published as @yarnpkg/cli-dist.
+func npmPackageFor(pm PackageManager) string {
+	if pm.Name == "yarn" {
+		if major, err := strconv.Atoi(strings.SplitN(pm.Version, ".", 2)[0]); err == nil && major >= 2 {
+			return "@yarnpkg/cli-dist@" + pm.Version
+		}
+	}
+	return pm.Name + "@" + pm.Version
+}
+
+func nodeBinDir(nodeHome string) string {
+	if platform.GetInfo().OS == "windows" {
+		return nodeHome
+	}
+	return filepath.Join(nodeHome, "bin")
+}
+
+func executableName(tool string) string {
+	if platform.GetInfo().OS == "windows" {
+		return tool + ".cmd"
+	}
+	return tool
+}
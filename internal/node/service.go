@@ -7,7 +7,10 @@ import (
 	"aem/pkg/errors"
 	"aem/pkg/filesystem"
 	"aem/pkg/logger"
+	"aem/pkg/settings"
+	"encoding/json"
 	"fmt"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -17,79 +20,143 @@ import (
 )
 
 type Service struct {
-	logger     *logger.Logger
-	downloader *downloader.Downloader
-	fs         *filesystem.FileSystem
-	zipper     *archiver.ZipExtractor
-	tarGz      *archiver.TarGzExtractor
-	installDir string
+	logger         *logger.Logger
+	downloader     *downloader.Downloader
+	fs             *filesystem.FileSystem
+	zipper         *archiver.ZipExtractor
+	tarGz          *archiver.TarGzExtractor
+	installDir     string
+	mirror         string
+	globalPackages []string
 }
 
 func NewService(logger *logger.Logger, installDir string) *Service {
+	cfg, err := settings.Load()
+	if err != nil {
+		logger.Debug("Using default Node.js mirror: %v", err)
+	}
+
 	return &Service{
-		logger:     logger,
-		downloader: downloader.New(logger),
-		fs:         filesystem.New(logger),
-		zipper:     archiver.NewZipExtractor(logger),
-		tarGz:      archiver.NewTarGzExtractor(logger),
-		installDir: installDir,
+		logger:         logger,
+		downloader:     downloader.New(logger),
+		fs:             filesystem.New(logger),
+		zipper:         archiver.NewZipExtractor(logger),
+		tarGz:          archiver.NewTarGzExtractor(logger),
+		installDir:     installDir,
+		mirror:         cfg.Mirror("node"),
+		globalPackages: cfg.GlobalPackages(),
 	}
 }
 
 func (s *Service) Install(majorVersion string) (string, error) {
 	s.logger.Debug("Installing Node.js version: %s", majorVersion)
 
-	// Normalize version format
-	if !strings.HasPrefix(majorVersion, "v") {
-		majorVersion = "v" + majorVersion
+	// Runtimes linked with 'aem link' are selected by name
+	if name, ok := s.fs.ExternalName(filepath.Join(s.installDir, "node"), majorVersion); ok {
+		return name, nil
+	}
+
+	latest, err := s.Resolve(majorVersion)
+	if err != nil {
+		return "", err
+	}
+	s.logger.Debug("Installing latest version: %s", latest)
+
+	unlock, err := s.fs.Lock()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	// Check if already installed
+	versionPath := filepath.Join(s.installDir, "node", latest)
+	if s.fs.InstallComplete(versionPath, platform.GetInfo().RuntimeBinary("node")) {
+		s.fs.MarkInstallComplete(versionPath)
+		s.logger.Debug("Node.js version %s already installed", latest)
+	} else {
+		// Download and install
+		downloadURL, err := s.getDownloadURL(latest)
+		if err != nil {
+			return "", err
+		}
+
+		if err := s.downloadAndInstall(downloadURL, strings.TrimPrefix(latest, "v")); err != nil {
+			return "", err
+		}
+		s.logger.Debug("Successfully installed Node.js version: %s", latest)
+	}
+
+	// Also for existing installs, so that a failed or changed package list is
+	// applied by the next run
+	if err := s.InstallGlobalPackages(latest, s.globalPackages); err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(latest, "v"), nil
+}
+
+// Resolve returns the newest remote release matching version, e.g. "v18.20.4"
+// for "18".
+func (s *Service) Resolve(version string) (string, error) {
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
 	}
 
-	// Get available versions
 	versions, err := s.GetVersions()
 	if err != nil {
 		return "", err
 	}
 
-	// Find matching versions
 	var matched []string
 	for _, v := range versions {
-		if strings.HasPrefix(v, majorVersion) {
+		if v == version || strings.HasPrefix(v, version+".") {
 			matched = append(matched, v)
 		}
 	}
-
 	if len(matched) == 0 {
-		return "", errors.NewValidationError("no Node.js versions found for major version: " + majorVersion)
-	}
-
-	// Use latest version
-	latest := matched[len(matched)-1]
-	s.logger.Debug("Installing latest version: %s", latest)
-
-	// Check if already installed
-	versionPath := filepath.Join(s.installDir, "node", latest)
-	if s.fs.Exists(versionPath) {
-		s.logger.Debug("Node.js version %s already installed", latest)
-		return strings.TrimPrefix(latest, "v"), nil
+		return "", errors.NewValidationError("no Node.js versions found for major version: " + version)
 	}
+	return matched[len(matched)-1], nil
+}
 
-	// Download and install
-	downloadURL, err := s.getDownloadURL(latest)
+// LatestLTS returns the newest LTS release listed in the mirror's index.json.
+func (s *Service) LatestLTS() (string, error) {
+	resp, err := s.downloader.GetHTML(s.mirror + "/index.json")
 	if err != nil {
-		return "", err
+		return "", errors.NewAPIError("failed to fetch Node.js releases", err)
 	}
+	defer resp.Close()
 
-	if err := s.downloadAndInstall(downloadURL, strings.TrimPrefix(latest, "v")); err != nil {
-		return "", err
+	var releases []struct {
+		Version string      `json:"version"`
+		LTS     interface{} `json:"lts"`
+	}
+	if err := json.NewDecoder(resp).Decode(&releases); err != nil {
+		return "", errors.NewAPIError("failed to parse Node.js releases", err)
 	}
 
-	s.logger.Debug("Successfully installed Node.js version: %s", latest)
-	return strings.TrimPrefix(latest, "v"), nil
+	latest := ""
+	for _, release := range releases {
+		// lts is false for current releases and the codename for LTS ones
+		if codename, ok := release.LTS.(string); !ok || codename == "" || !semver.IsValid(release.Version) {
+			continue
+		}
+		if latest == "" || semver.Compare(release.Version, latest) > 0 {
+			latest = release.Version
+		}
+	}
+	if latest == "" {
+		return "", errors.NewAPIError("no LTS release found in "+s.mirror+"/index.json", nil)
+	}
+	return latest, nil
 }
 
 func (s *Service) Use(version string, symlinkPath string) error {
 	s.logger.Debug("Setting Node.js version: %s", version)
 
+	if name, ok := s.fs.ExternalName(filepath.Join(s.installDir, "node"), version); ok {
+		version = name
+	}
+
 	// Handle both with and without 'v' prefix
 	versionPath := filepath.Join(s.installDir, "node", version)
 	if !s.fs.Exists(versionPath) {
@@ -142,7 +209,7 @@ func (s *Service) List() ([]string, error) {
 
 	var versions []string
 	for _, entry := range entries {
-		if entry.IsDir() {
+		if entry.IsDir() && !filesystem.IsInstallBackup(entry.Name()) {
 			version := entry.Name()
 			cleanVersion := strings.TrimPrefix(version, "v")
 			prefix := "   "
@@ -159,7 +226,7 @@ func (s *Service) List() ([]string, error) {
 func (s *Service) GetVersions() ([]string, error) {
 	s.logger.Debug("Fetching Node.js versions")
 
-	resp, err := s.downloader.GetHTML("https://nodejs.org/dist/")
+	resp, err := s.downloader.GetHTML(s.mirror + "/")
 	if err != nil {
 		return nil, errors.NewAPIError("failed to fetch Node.js versions", err)
 	}
@@ -205,7 +272,7 @@ func (s *Service) getDownloadURL(version string) (string, error) {
 		archiveSuffix = ".zip"
 	}
 
-	url := "https://nodejs.org/dist/" + version
+	url := s.mirror + "/" + version
 	s.logger.Debug("Searching for Node.js binary at: %s", url)
 
 	resp, err := s.downloader.GetHTML(url)
@@ -227,7 +294,7 @@ func (s *Service) getDownloadURL(version string) (string, error) {
 				if attr.Key == "href" &&
 					strings.Contains(attr.Val, target) &&
 					strings.HasSuffix(attr.Val, archiveSuffix) {
-					downloadURL = "https://nodejs.org" + attr.Val
+					downloadURL = url + "/" + path.Base(attr.Val)
 					return
 				}
 			}
@@ -246,26 +313,16 @@ func (s *Service) getDownloadURL(version string) (string, error) {
 }
 
 func (s *Service) downloadAndInstall(url, version string) error {
-	// Get temp directory from AEM_HOME
-	tmpDir, err := s.fs.GetTempDir()
+	stagingDir, err := s.fs.NewStagingDir("node")
 	if err != nil {
 		return err
 	}
+	defer s.fs.RemoveAll(stagingDir)
 
-	fileName := filepath.Base(url)
-	zipPath := filepath.Join(tmpDir, fileName)
-	extractDir := filepath.Join(tmpDir, "node_extract")
+	zipPath := filepath.Join(stagingDir, filepath.Base(url))
+	extractDir := filepath.Join(stagingDir, "extract")
 	finalPath := filepath.Join(s.installDir, "node", "v"+version)
 
-	// Ensure cleanup
-	defer func() {
-		s.fs.RemoveAll(zipPath)
-		s.fs.RemoveAll(extractDir)
-	}()
-
-	s.fs.RemoveAll(zipPath)
-	s.fs.RemoveAll(extractDir)
-
 	// Download
 	if err := s.downloader.Download(url, zipPath); err != nil {
 		return err
@@ -295,16 +352,8 @@ func (s *Service) downloadAndInstall(url, version string) error {
 		return errors.NewExtractionError("expected single root directory in Node.js archive", nil)
 	}
 
-	extractedRoot := filepath.Join(extractDir, entries[0].Name())
-
-	// Ensure destination directory exists
-	if err := s.fs.EnsureDir(filepath.Dir(finalPath)); err != nil {
-		return err
-	}
-
 	// Move to final location
-	s.fs.RemoveAll(finalPath) // Remove if exists
-	return s.fs.Move(extractedRoot, finalPath)
+	return s.fs.CommitInstall(filepath.Join(extractDir, entries[0].Name()), finalPath)
 }
 
 func (s *Service) GetCurrentNodeVersion() (string, error) {
@@ -315,17 +364,33 @@ func (s *Service) GetCurrentNodeVersion() (string, error) {
 	return state.CurrentNodeVersion()
 }
 
+func (s *Service) CanUninstall(version string) error {
+	currentVersion, err := s.GetCurrentNodeVersion()
+	if err != nil {
+		return err
+	}
+
+	if currentVersion != "" && currentVersion == strings.TrimPrefix(version, "v") {
+		return errors.UninstallError(fmt.Sprintf("cannot uninstall version %s as it's the currently active version", version), nil)
+	}
+
+	if name, ok := s.fs.ExternalName(filepath.Join(s.installDir, "node"), version); ok {
+		return errors.UninstallError(fmt.Sprintf("cannot uninstall %s as it's an external runtime, run 'aem link --remove node %s' to unregister it", name, name), nil)
+	}
+	return nil
+}
+
 func (s *Service) Uninstall(majorVersion string) error {
 	s.logger.Debug("Un-installing Node version: %s", majorVersion)
 
-	// Check if the environment is being set
-	currentVersion, err := s.GetCurrentNodeVersion()
+	unlock, err := s.fs.Lock()
 	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	if currentVersion == majorVersion || currentVersion == "v"+majorVersion {
-		return errors.UninstallError(fmt.Sprintf("cannot uninstall version %s as it's the currently active version", majorVersion), nil)
+	if err := s.CanUninstall(majorVersion); err != nil {
+		return err
 	}
 
 	// Try both with and without 'v' prefix
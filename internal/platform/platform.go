@@ -1,6 +1,7 @@
 package platform
 
 import (
+	"path/filepath"
 	"runtime"
 )
 
@@ -60,3 +61,88 @@ func (p Info) GetNodeTarget() string {
 		return p.OS + "-" + p.Arch
 	}
 }
+
+// GetPythonTarget returns the target triple python-build-standalone archives
+// are named after, e.g. "x86_64-unknown-linux-gnu".
+func (p Info) GetPythonTarget() string {
+	arch := p.Arch
+	switch p.Arch {
+	case "amd64":
+		arch = "x86_64"
+	case "arm64":
+		arch = "aarch64"
+	case "386":
+		arch = "i686"
+	}
+
+	switch p.OS {
+	case "darwin":
+		return arch + "-apple-darwin"
+	case "windows":
+		return arch + "-pc-windows-msvc"
+	default:
+		return arch + "-unknown-" + p.OS + "-gnu"
+	}
+}
+
+// GetRubyTarget returns the platform a Ruby index lists builds under, the
+// RUBY_PLATFORM of a Ruby built for it, e.g. "x86_64-linux" or "arm64-darwin".
+func (p Info) GetRubyTarget() string {
+	switch p.OS {
+	case "darwin":
+		if p.Arch == "arm64" {
+			return "arm64-darwin"
+		}
+		return "x86_64-darwin"
+	case "windows":
+		if p.Arch == "arm64" {
+			return "aarch64-mingw-ucrt"
+		}
+		return "x64-mingw-ucrt"
+	}
+
+	switch p.Arch {
+	case "amd64":
+		return "x86_64-" + p.OS
+	case "arm64":
+		return "aarch64-" + p.OS
+	case "386":
+		return "x86-" + p.OS
+	default:
+		return p.Arch + "-" + p.OS
+	}
+}
+
+// RuntimeBinary returns the main executable of an installed runtime,
+// relative to its install directory.
+func (p Info) RuntimeBinary(module string) string {
+	switch module {
+	case "node":
+		if p.OS == "windows" {
+			return "node.exe"
+		}
+		return filepath.Join("bin", "node")
+	case "java":
+		if p.OS == "windows" {
+			return filepath.Join("bin", "java.exe")
+		}
+		return filepath.Join("bin", "java")
+	case "gradle":
+		if p.OS == "windows" {
+			return filepath.Join("bin", "gradle.bat")
+		}
+		return filepath.Join("bin", "gradle")
+	case "ruby":
+		if p.OS == "windows" {
+			return filepath.Join("bin", "ruby.exe")
+		}
+		return filepath.Join("bin", "ruby")
+	case "python":
+		if p.OS == "windows" {
+			return "python.exe"
+		}
+		return filepath.Join("bin", "python3")
+	default:
+		return ""
+	}
+}
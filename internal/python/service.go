@@ -0,0 +1,93 @@
+package python
+
+import (
+	"aem/internal/platform"
+	"aem/internal/runtimes"
+	"aem/pkg/downloader"
+	"aem/pkg/logger"
+	"aem/pkg/settings"
+	"regexp"
+	"sort"
+
+	"golang.org/x/mod/semver"
+)
+
+// assetPattern matches install_only archives such as
+// "cpython-3.12.7+20241016-x86_64-unknown-linux-gnu-install_only.tar.gz".
+// Older Windows builds carry a "-shared" suffix on the triple.
+var assetPattern = regexp.MustCompile(`^cpython-([0-9]+\.[0-9]+\.[0-9]+)\+([0-9]+)-(.+?)(-shared)?-install_only\.tar\.gz$`)
+
+// Service installs python-build-standalone builds of CPython.
+type Service struct {
+	*runtimes.Service
+}
+
+// GitHubRelease is the part of a GitHub release the service reads.
+type GitHubRelease struct {
+	TagName string        `json:"tag_name"`
+	Assets  []GitHubAsset `json:"assets"`
+}
+
+type GitHubAsset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// Release is a CPython version with its install_only archive for this
+// platform, from the newest python-build-standalone release that built it.
+type Release struct {
+	Version string
+	Build   string
+	URL     string
+}
+
+func NewService(logger *logger.Logger, installDir string) *Service {
+	cfg, err := settings.Load()
+	if err != nil {
+		logger.Debug("Using default Python mirror: %v", err)
+	}
+
+	target := platform.GetInfo().GetPythonTarget()
+	layout := runtimes.Layout{
+		Name:            "python",
+		Title:           "Python",
+		Archive:         "tar.gz",
+		StripComponents: 1,
+		Probe:           platform.GetInfo().RuntimeBinary("python"),
+		Platform:        target,
+	}
+	return &Service{Service: runtimes.New(logger, installDir, layout, &source{
+		logger:     logger,
+		downloader: downloader.New(logger),
+		mirror:     cfg.Mirror("python"),
+		target:     target,
+		cacheDir:   cfg.String("cache.dir"),
+	})}
+}
+
+// ParseReleases picks the install_only archive of target for every CPython
+// version in githubReleases, preferring the newest build.
+func ParseReleases(githubReleases []GitHubRelease, target string) []Release {
+	builds := make(map[string]Release)
+	for _, githubRelease := range githubReleases {
+		for _, asset := range githubRelease.Assets {
+			match := assetPattern.FindStringSubmatch(asset.Name)
+			if match == nil || match[3] != target {
+				continue
+			}
+			if existing, ok := builds[match[1]]; ok && existing.Build >= match[2] {
+				continue
+			}
+			builds[match[1]] = Release{Version: match[1], Build: match[2], URL: asset.BrowserDownloadURL}
+		}
+	}
+
+	releases := make([]Release, 0, len(builds))
+	for _, release := range builds {
+		releases = append(releases, release)
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return semver.Compare("v"+releases[i].Version, "v"+releases[j].Version) < 0
+	})
+	return releases
+}
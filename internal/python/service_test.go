@@ -0,0 +1,128 @@
+package python
+
+import (
+	"aem/internal/platform"
+	"aem/pkg/logger"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newPagedMirror serves three pages of releases, newest first, linking each
+// page to the next like the GitHub API. It counts the requests made.
+func newPagedMirror(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	target := platform.GetInfo().GetPythonTarget()
+	asset := func(version, build string) GitHubAsset {
+		name := fmt.Sprintf("cpython-%s+%s-%s-install_only.tar.gz", version, build, target)
+		return GitHubAsset{Name: name, BrowserDownloadURL: "https://example.com/" + name}
+	}
+	pages := [][]GitHubRelease{
+		{{TagName: "20241206", Assets: []GitHubAsset{asset("3.13.1", "20241206"), asset("3.12.8", "20241206")}}},
+		{{TagName: "20241016", Assets: []GitHubAsset{asset("3.12.7", "20241016"), asset("3.9.20", "20241016")}}},
+		{{TagName: "20240909", Assets: []GitHubAsset{asset("3.8.20", "20240909")}}},
+	}
+
+	var requests atomic.Int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		n, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil || n < 1 || n > len(pages) {
+			http.NotFound(w, r)
+			return
+		}
+		if n < len(pages) {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/releases?page=%d>; rel="next", <%s/releases?page=%d>; rel="last"`, server.URL, n+1, server.URL, len(pages)))
+		}
+		json.NewEncoder(w).Encode(pages[n-1])
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestResolvePages(t *testing.T) {
+	server, requests := newPagedMirror(t)
+	home := t.TempDir()
+	t.Setenv("AEM_HOME", home)
+	t.Setenv("AEM_PYTHON_MIRROR", server.URL)
+	t.Setenv("AEM_CACHE_DIR", filepath.Join(home, "cache"))
+	service := NewService(logger.New(false), filepath.Join(home, "sys_installed"))
+
+	tests := []struct {
+		version  string
+		want     string
+		wantErr  string
+		requests int32
+	}{
+		{version: "3.13", want: "v3.13.1", requests: 1},
+		{version: "3.12", want: "v3.12.8", requests: 0},
+		{version: "3.9", want: "v3.9.20", requests: 1},
+		{version: "3.8", want: "v3.8.20", requests: 1},
+		// Every page is cached now, so nothing else is requested
+		{version: "3.8.20", want: "v3.8.20", requests: 0},
+		{version: "3.14", wantErr: "no Python releases found for version 3.14", requests: 0},
+		{version: "3.7", wantErr: "no Python releases found for version 3.7", requests: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			before := requests.Load()
+			got, err := service.Resolve(tt.version)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve(%q) error = %v, want %q", tt.version, err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Resolve(%q) error = %v", tt.version, err)
+			} else if got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.version, got, tt.want)
+			}
+			if made := requests.Load() - before; made != tt.requests {
+				t.Errorf("Resolve(%q) made %d requests, want %d", tt.version, made, tt.requests)
+			}
+		})
+	}
+}
+
+func TestRequestHeader(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "secret")
+
+	tests := []struct {
+		mirror string
+		want   string
+	}{
+		{mirror: "https://api.github.com/repos/astral-sh/python-build-standalone", want: "Bearer secret"},
+		{mirror: "https://mirror.example.com/python", want: ""},
+		{mirror: "http://api.github.com/repos/astral-sh/python-build-standalone", want: ""},
+	}
+	for _, tt := range tests {
+		if got := RequestHeader(tt.mirror).Get("Authorization"); got != tt.want {
+			t.Errorf("RequestHeader(%q) Authorization = %q, want %q", tt.mirror, got, tt.want)
+		}
+	}
+}
+
+func TestHasNextPage(t *testing.T) {
+	tests := []struct {
+		link string
+		want bool
+	}{
+		{link: "", want: false},
+		{link: `<https://api.github.com/releases?page=2>; rel="next", <https://api.github.com/releases?page=9>; rel="last"`, want: true},
+		{link: `<https://api.github.com/releases?page=1>; rel="prev", <https://api.github.com/releases?page=1>; rel="first"`, want: false},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		header.Set("Link", tt.link)
+		if got := HasNextPage(header); got != tt.want {
+			t.Errorf("HasNextPage(%q) = %v, want %v", tt.link, got, tt.want)
+		}
+	}
+}
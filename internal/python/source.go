@@ -0,0 +1,182 @@
+package python
+
+import (
+	"aem/internal/runtimes"
+	"aem/pkg/downloader"
+	"aem/pkg/errors"
+	"aem/pkg/logger"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// releasesPerPage is small because every python-build-standalone release
+	// carries about a thousand assets.
+	releasesPerPage = 10
+	// maxPages bounds how far back a version is looked for, about two years
+	// of releases.
+	maxPages = 20
+	// pageTTL is how long a page read from the GitHub API is reused. It keeps
+	// repeated installs within the unauthenticated limit of 60 requests an
+	// hour.
+	pageTTL = time.Hour
+)
+
+// source lists the CPython versions with an install_only build for target,
+// a page of python-build-standalone releases at a time.
+type source struct {
+	logger     *logger.Logger
+	downloader *downloader.Downloader
+	mirror     string
+	target     string
+	cacheDir   string
+}
+
+// cachedPage is a page of releases as kept in the cache directory.
+type cachedPage struct {
+	Fetched  time.Time          `json:"fetched"`
+	Releases []runtimes.Release `json:"releases"`
+	More     bool               `json:"more"`
+}
+
+// Releases returns the versions built by the newest releases.
+func (s *source) Releases() ([]runtimes.Release, error) {
+	releases, _, err := s.Page(1)
+	return releases, err
+}
+
+func (s *source) Page(n int) ([]runtimes.Release, bool, error) {
+	cached, ok := s.readCache(n)
+	if ok && time.Since(cached.Fetched) < pageTTL {
+		return cached.Releases, cached.More, nil
+	}
+
+	page, err := s.fetch(n)
+	if err != nil {
+		if ok {
+			s.logger.Debug("Using Python releases cached at %s: %v", cached.Fetched.Format(time.RFC3339), err)
+			return cached.Releases, cached.More, nil
+		}
+		return nil, false, err
+	}
+	s.writeCache(n, page)
+	return page.Releases, page.More, nil
+}
+
+func (s *source) fetch(n int) (*cachedPage, error) {
+	apiURL := ReleasesURL(s.mirror, n)
+	s.logger.Debug("Fetching Python releases from: %s", apiURL)
+
+	resp, err := s.downloader.Get(apiURL, RequestHeader(s.mirror))
+	if err != nil {
+		return nil, errors.NewAPIError("failed to fetch Python releases", err)
+	}
+	defer resp.Body.Close()
+
+	var githubReleases []GitHubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&githubReleases); err != nil {
+		return nil, errors.NewAPIError("failed to parse Python releases", err)
+	}
+
+	page := &cachedPage{
+		Fetched:  time.Now(),
+		Releases: []runtimes.Release{},
+		More:     HasNextPage(resp.Header) && n < maxPages,
+	}
+	for _, release := range ParseReleases(githubReleases, s.target) {
+		page.Releases = append(page.Releases, runtimes.Release{Version: "v" + release.Version, URL: release.URL})
+	}
+	return page, nil
+}
+
+// cachePath returns the file page n of the mirror's releases for target is
+// kept in, or "" when there is no cache directory.
+func (s *source) cachePath(n int) string {
+	if s.cacheDir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(s.mirror + "\n" + s.target))
+	return filepath.Join(s.cacheDir, "python", fmt.Sprintf("%s-%d.json", hex.EncodeToString(sum[:8]), n))
+}
+
+func (s *source) readCache(n int) (*cachedPage, bool) {
+	path := s.cachePath(n)
+	if path == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var page cachedPage
+	if err := json.Unmarshal(data, &page); err != nil {
+		s.logger.Debug("Ignoring cached Python releases %s: %v", path, err)
+		return nil, false
+	}
+	return &page, true
+}
+
+func (s *source) writeCache(n int, page *cachedPage) {
+	path := s.cachePath(n)
+	if path == "" {
+		return
+	}
+	data, err := json.Marshal(page)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		s.logger.Debug("Failed to cache Python releases: %v", err)
+	}
+}
+
+// ReleasesURL returns the GitHub API URL of page n of the releases of the
+// python-build-standalone repository at mirror, newest first.
+func ReleasesURL(mirror string, n int) string {
+	return fmt.Sprintf("%s/releases?per_page=%d&page=%d", strings.TrimSuffix(mirror, "/"), releasesPerPage, n)
+}
+
+// RequestHeader returns the headers of a request to the releases at mirror.
+// GITHUB_TOKEN, or GH_TOKEN, authenticates requests to api.github.com, which
+// raises the rate limit from 60 to 5000 requests an hour. It is never sent
+// to other mirrors.
+func RequestHeader(mirror string) http.Header {
+	header := http.Header{}
+	header.Set("Accept", "application/vnd.github+json")
+
+	u, err := neturl.Parse(mirror)
+	if err != nil || u.Scheme != "https" || u.Host != "api.github.com" {
+		return header
+	}
+	for _, name := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if token := strings.TrimSpace(os.Getenv(name)); token != "" {
+			header.Set("Authorization", "Bearer "+token)
+			break
+		}
+	}
+	return header
+}
+
+// HasNextPage reports whether the Link header of a GitHub API response
+// points at a next page.
+func HasNextPage(header http.Header) bool {
+	for _, link := range strings.Split(header.Get("Link"), ",") {
+		for _, param := range strings.Split(link, ";")[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return true
+			}
+		}
+	}
+	return false
+}
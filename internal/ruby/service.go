@@ -0,0 +1,122 @@
+package ruby
+
+import (
+	"aem/internal/platform"
+	"aem/internal/runtimes"
+	"aem/pkg/downloader"
+	"aem/pkg/errors"
+	"aem/pkg/logger"
+	"aem/pkg/settings"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// Service installs Ruby builds listed by the index.json of the configured
+// mirror.
+type Service struct {
+	*runtimes.Service
+}
+
+// Release is an entry of the Ruby index at <mirror>/index.json. Files maps a
+// RUBY_PLATFORM such as "x86_64-linux", "arm64-darwin" or "x64-mingw-ucrt" to
+// an archive URL, relative to the mirror or absolute. Archives are .zip on
+// Windows and .tar.gz elsewhere.
+type Release struct {
+	Version string            `json:"version"`
+	Files   map[string]string `json:"files"`
+}
+
+func NewService(logger *logger.Logger, installDir string) *Service {
+	cfg, err := settings.Load()
+	if err != nil {
+		logger.Debug("Using default Ruby mirror: %v", err)
+	}
+
+	archive := "tar.gz"
+	if platform.GetInfo().OS == "windows" {
+		archive = "zip"
+	}
+	target := platform.GetInfo().GetRubyTarget()
+	layout := runtimes.Layout{
+		Name:            "ruby",
+		Title:           "Ruby",
+		Archive:         archive,
+		StripComponents: 1,
+		Probe:           platform.GetInfo().RuntimeBinary("ruby"),
+		Platform:        target,
+	}
+	return &Service{Service: runtimes.New(logger, installDir, layout, &source{
+		logger:     logger,
+		downloader: downloader.New(logger),
+		mirror:     cfg.Mirror("ruby"),
+		target:     target,
+	})}
+}
+
+// source lists the releases of the index with a build for target.
+type source struct {
+	logger     *logger.Logger
+	downloader *downloader.Downloader
+	mirror     string
+	target     string
+}
+
+func (s *source) Releases() ([]runtimes.Release, error) {
+	if s.mirror == "" {
+		return nil, errors.NewValidationError("no Ruby index configured, run 'aem config set mirrors.ruby <url>' with the URL serving index.json")
+	}
+
+	apiURL := IndexURL(s.mirror)
+	s.logger.Debug("Fetching Ruby releases from: %s", apiURL)
+
+	resp, err := s.downloader.GetHTML(apiURL)
+	if err != nil {
+		return nil, errors.NewAPIError("failed to fetch Ruby releases", err)
+	}
+	defer resp.Close()
+
+	data, err := io.ReadAll(resp)
+	if err != nil {
+		return nil, errors.NewAPIError("failed to read Ruby releases", err)
+	}
+	releases, err := ParseIndex(data, s.target, s.mirror)
+	if err != nil {
+		return nil, errors.NewAPIError("failed to parse Ruby releases", err)
+	}
+	return releases, nil
+}
+
+// IndexURL returns the URL of the index served by mirror.
+func IndexURL(mirror string) string {
+	return strings.TrimSuffix(mirror, "/") + "/index.json"
+}
+
+// ParseIndex returns the releases of an index with a build for target, oldest
+// first, resolving relative archive paths against mirror.
+func ParseIndex(data []byte, target, mirror string) ([]runtimes.Release, error) {
+	var all []Release
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	var releases []runtimes.Release
+	for _, release := range all {
+		file, ok := release.Files[target]
+		version := "v" + strings.TrimPrefix(release.Version, "v")
+		if !ok || !semver.IsValid(version) {
+			continue
+		}
+		if !strings.Contains(file, "://") {
+			file = strings.TrimSuffix(mirror, "/") + "/" + strings.TrimPrefix(file, "/")
+		}
+		releases = append(releases, runtimes.Release{Version: version, URL: file})
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return semver.Compare(releases[i].Version, releases[j].Version) < 0
+	})
+	return releases, nil
+}
@@ -0,0 +1,218 @@
+package ruby
+
+import (
+	"aem/internal/platform"
+	"aem/pkg/logger"
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// mirror serves an index.json and the archives it lists, counting archive
+// downloads.
+type mirror struct {
+	*httptest.Server
+	downloads atomic.Int32
+}
+
+func newMirror(t *testing.T) *mirror {
+	t.Helper()
+
+	target := platform.GetInfo().GetRubyTarget()
+	m := &mirror{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/index.json", func(w http.ResponseWriter, r *http.Request) {
+		index := []Release{
+			{Version: "3.3.6", Files: map[string]string{target: "ruby-3.3.6.tar.gz"}},
+			{Version: "3.2.5", Files: map[string]string{target: m.URL + "/ruby-3.2.5.tar.gz"}},
+			{Version: "3.3.0", Files: map[string]string{target: "/ruby-3.3.0.tar.gz"}},
+			{Version: "3.4.1", Files: map[string]string{"other-platform": "ruby-3.4.1.tar.gz"}},
+			{Version: "head", Files: map[string]string{target: "ruby-head.tar.gz"}},
+		}
+		json.NewEncoder(w).Encode(index)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), ".tar.gz")
+		m.downloads.Add(1)
+		w.Write(rubyArchive(t, name))
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+// rubyArchive returns a tar.gz holding <root>/bin/ruby, like the archives of
+// ruby/ruby-builder.
+func rubyArchive(t *testing.T, root string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, dir := range []string{root + "/", root + "/bin/"} {
+		if err := tw.WriteHeader(&tar.Header{Name: dir, Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	binary := filepath.ToSlash(filepath.Join(root, platform.GetInfo().RuntimeBinary("ruby")))
+	body := []byte("#!/bin/sh\n")
+	if err := tw.WriteHeader(&tar.Header{Name: binary, Typeflag: tar.TypeReg, Mode: 0755, Size: int64(len(body))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(body); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newTestService returns a service installing into a fresh AEM_HOME from
+// mirrorURL, or with no mirror configured when it is empty.
+func newTestService(t *testing.T, mirrorURL string) (*Service, string) {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("AEM_HOME", home)
+	t.Setenv("AEM_RUBY_MIRROR", mirrorURL)
+	t.Setenv("AEM_CACHE_DIR", filepath.Join(home, "cache"))
+
+	installDir := filepath.Join(home, "sys_installed")
+	return NewService(logger.New(false), installDir), installDir
+}
+
+func TestReleases(t *testing.T) {
+	m := newMirror(t)
+	service, _ := newTestService(t, m.URL)
+
+	releases, err := service.Releases()
+	if err != nil {
+		t.Fatalf("Releases() error = %v", err)
+	}
+
+	want := map[string]string{
+		"v3.2.5": m.URL + "/ruby-3.2.5.tar.gz",
+		"v3.3.0": m.URL + "/ruby-3.3.0.tar.gz",
+		"v3.3.6": m.URL + "/ruby-3.3.6.tar.gz",
+	}
+	order := []string{"v3.2.5", "v3.3.0", "v3.3.6"}
+	if len(releases) != len(order) {
+		t.Fatalf("Releases() = %v, want %v", releases, order)
+	}
+	for i, release := range releases {
+		if release.Version != order[i] {
+			t.Errorf("Releases()[%d].Version = %q, want %q", i, release.Version, order[i])
+		}
+		if release.URL != want[release.Version] {
+			t.Errorf("URL of %s = %q, want %q", release.Version, release.URL, want[release.Version])
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	m := newMirror(t)
+	service, _ := newTestService(t, m.URL)
+
+	tests := []struct {
+		version string
+		want    string
+		wantErr string
+	}{
+		{version: "3", want: "v3.3.6"},
+		{version: "3.3", want: "v3.3.6"},
+		{version: "v3.2", want: "v3.2.5"},
+		{version: "3.3.0", want: "v3.3.0"},
+		{version: "3.4", wantErr: "no Ruby releases found for version 3.4 on " + platform.GetInfo().GetRubyTarget()},
+		{version: "2", wantErr: "no Ruby releases found for version 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := service.Resolve(tt.version)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve(%q) error = %v, want %q", tt.version, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q) error = %v", tt.version, err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstallAndUse(t *testing.T) {
+	m := newMirror(t)
+	service, installDir := newTestService(t, m.URL)
+
+	version, err := service.Install("3.3")
+	if err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if version != "v3.3.6" {
+		t.Fatalf("Install() = %q, want v3.3.6", version)
+	}
+	binary := filepath.Join(installDir, "ruby", "v3.3.6", platform.GetInfo().RuntimeBinary("ruby"))
+	if _, err := os.Stat(binary); err != nil {
+		t.Fatalf("installed binary missing: %v", err)
+	}
+
+	// The install is complete, so a second Install does not download again
+	if _, err := service.Install("3.3.6"); err != nil {
+		t.Fatalf("second Install() error = %v", err)
+	}
+	if got := m.downloads.Load(); got != 1 {
+		t.Errorf("downloads = %d, want 1", got)
+	}
+
+	symlinkPath, err := service.SymlinkPath()
+	if err != nil {
+		t.Fatalf("SymlinkPath() error = %v", err)
+	}
+	if err := service.Use(version, symlinkPath); err != nil {
+		t.Fatalf("Use() error = %v", err)
+	}
+	current, err := service.GetCurrentVersion()
+	if err != nil {
+		t.Fatalf("GetCurrentVersion() error = %v", err)
+	}
+	if current != "3.3.6" {
+		t.Errorf("GetCurrentVersion() = %q, want 3.3.6", current)
+	}
+
+	if err := service.CanUninstall(version); err == nil {
+		t.Error("CanUninstall() of the active version succeeded")
+	}
+	if err := service.Use("3.2.5", symlinkPath); err == nil || !strings.Contains(err.Error(), "Ruby version not installed: 3.2.5") {
+		t.Errorf("Use() of a missing version error = %v", err)
+	}
+}
+
+func TestUnconfiguredMirror(t *testing.T) {
+	service, _ := newTestService(t, "")
+
+	for name, call := range map[string]func() error{
+		"Releases": func() error { _, err := service.Releases(); return err },
+		"Install":  func() error { _, err := service.Install("3.3"); return err },
+	} {
+		err := call()
+		if err == nil || !strings.Contains(err.Error(), "no Ruby index configured") {
+			t.Errorf("%s() error = %v, want the unconfigured mirror error", name, err)
+		}
+	}
+}
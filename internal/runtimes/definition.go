@@ -0,0 +1,422 @@
+package runtimes
+
+import (
+	"aem/internal/environment"
+	"aem/internal/platform"
+	"aem/pkg/downloader"
+	"aem/pkg/errors"
+	"aem/pkg/logger"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dir is the directory of AEM_HOME holding runtime definitions.
+const Dir = "runtimes"
+
+// builtinModules cannot be redefined, their names already mean something to
+// every command and to aem.json.
+var builtinModules = []string{"node", "java", "jdk", "gradle", "ruby", "python", "android"}
+
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// Definition describes a runtime aem installs without a dedicated service,
+// read from AEM_HOME/runtimes/<name>.json.
+type Definition struct {
+	Name string `json:"name"`
+	// Index is fetched to list the available versions.
+	Index string `json:"index"`
+	// VersionPath selects versions from a JSON index, e.g. "$[*].tag_name".
+	VersionPath string `json:"versionPath"`
+	// VersionRegex extracts a version from the index, or from each value
+	// VersionPath selected, using its first group.
+	VersionRegex string `json:"versionRegex"`
+	// URL is the download URL template, with {version}, {os} and {arch}.
+	URL string `json:"url"`
+	// OS and Arch override the {os} and {arch} values per GOOS and GOARCH.
+	OS              map[string]string `json:"os"`
+	Arch            map[string]string `json:"arch"`
+	Archive         string            `json:"archive"`
+	StripComponents int               `json:"stripComponents"`
+	Bin             []string          `json:"bin"`
+	HomeVariable    string            `json:"homeVariable"`
+
+	path         string
+	versionRegex *regexp.Regexp
+}
+
+// Load reads every definition in AEM_HOME/runtimes, sorted by name. A missing
+// directory yields no definitions.
+func Load(aemHome string) ([]*Definition, error) {
+	paths, err := filepath.Glob(filepath.Join(aemHome, Dir, "*.json"))
+	if err != nil {
+		return nil, errors.NewFileSystemError("failed to list runtime definitions", err)
+	}
+
+	var defs []*Definition
+	for _, path := range paths {
+		def, err := loadDefinition(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, existing := range defs {
+			if existing.Name == def.Name {
+				return nil, errors.NewValidationError(fmt.Sprintf("runtime %q is defined by both %s and %s", def.Name, existing.path, def.path))
+			}
+			if def.HomeVariable != "" && strings.EqualFold(existing.HomeVariable, def.HomeVariable) {
+				return nil, errors.NewValidationError(fmt.Sprintf("%s is the homeVariable of both %s and %s", def.HomeVariable, existing.path, def.path))
+			}
+		}
+		defs = append(defs, def)
+	}
+
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].Name < defs[j].Name
+	})
+	return defs, nil
+}
+
+// Find returns the definition of the runtime called name.
+func Find(aemHome, name string) (*Definition, bool, error) {
+	defs, err := Load(aemHome)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, def := range defs {
+		if def.Name == name {
+			return def, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func loadDefinition(path string) (*Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.NewFileSystemError("failed to read "+path, err)
+	}
+
+	def := &Definition{path: path}
+	if err := json.Unmarshal(data, def); err != nil {
+		return nil, errors.NewValidationError(fmt.Sprintf("invalid runtime definition %s: %v", path, err))
+	}
+	if def.Name == "" {
+		def.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+	}
+	if err := def.validate(); err != nil {
+		return nil, errors.NewValidationError(fmt.Sprintf("invalid runtime definition %s: %v", path, err))
+	}
+	return def, nil
+}
+
+func (d *Definition) validate() error {
+	if !namePattern.MatchString(d.Name) {
+		return fmt.Errorf("name %q must be a lowercase letter followed by lowercase letters, digits or '-'", d.Name)
+	}
+	for _, module := range builtinModules {
+		if d.Name == module {
+			return fmt.Errorf("name %q is a built-in module", d.Name)
+		}
+	}
+	if d.Index == "" {
+		return fmt.Errorf("index is required")
+	}
+	if d.VersionPath == "" && d.VersionRegex == "" {
+		return fmt.Errorf("versionPath or versionRegex is required")
+	}
+	if d.VersionPath != "" {
+		if _, err := parseJSONPath(d.VersionPath); err != nil {
+			return fmt.Errorf("versionPath: %v", err)
+		}
+	}
+	if d.VersionRegex != "" {
+		pattern, err := regexp.Compile(d.VersionRegex)
+		if err != nil {
+			return fmt.Errorf("versionRegex: %v", err)
+		}
+		d.versionRegex = pattern
+	}
+	if d.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+
+	if d.Archive == "" {
+		d.Archive = archiveFromURL(d.URL)
+	}
+	switch d.Archive {
+	case "tar.gz", "zip", "binary":
+	case "tgz":
+		d.Archive = "tar.gz"
+	default:
+		return fmt.Errorf("archive %q must be tar.gz, zip or binary", d.Archive)
+	}
+
+	if d.StripComponents < 0 {
+		return fmt.Errorf("stripComponents must not be negative")
+	}
+	if len(d.Bin) == 0 {
+		d.Bin = []string{"bin"}
+	}
+	for _, dir := range d.Bin {
+		if filepath.IsAbs(dir) || strings.HasPrefix(filepath.Clean(dir), "..") {
+			return fmt.Errorf("bin %q must be relative to the install directory", dir)
+		}
+	}
+	if d.HomeVariable != "" {
+		if err := environment.CheckToolVariable(d.HomeVariable); err != nil {
+			return fmt.Errorf("homeVariable: %v", err)
+		}
+	}
+	return nil
+}
+
+// DownloadURL expands the URL template for version on this platform.
+func (d *Definition) DownloadURL(version string) string {
+	info := platform.GetInfo()
+
+	osName := info.OS
+	if value, ok := d.OS[info.OS]; ok {
+		osName = value
+	}
+	arch := info.MapArchitecture()
+	if value, ok := d.Arch[info.Arch]; ok {
+		arch = value
+	}
+
+	return strings.NewReplacer(
+		"{version}", strings.TrimPrefix(version, "v"),
+		"{os}", osName,
+		"{arch}", arch,
+	).Replace(d.URL)
+}
+
+// BinDirs returns the directories of an install at root that go on PATH.
+func (d *Definition) BinDirs(root string) []string {
+	dirs := make([]string, 0, len(d.Bin))
+	for _, dir := range d.Bin {
+		dirs = append(dirs, filepath.Join(root, dir))
+	}
+	return dirs
+}
+
+// ToolHome describes the install at root for environment.Options.
+func (d *Definition) ToolHome(root string) environment.ToolHome {
+	return environment.ToolHome{
+		Name:         d.Name,
+		Home:         root,
+		HomeVariable: d.HomeVariable,
+		BinDirs:      d.BinDirs(root),
+	}
+}
+
+// Probe is the path, relative to an install, that must exist for it to be
+// considered complete.
+func (d *Definition) Probe() string {
+	return d.Bin[0]
+}
+
+func (d *Definition) layout() Layout {
+	return Layout{
+		Name:            d.Name,
+		Title:           d.Name,
+		Archive:         d.Archive,
+		StripComponents: d.StripComponents,
+		Probe:           d.Probe(),
+		Origin:          d.path,
+	}
+}
+
+// definitionSource lists the releases of a definition from its index.
+type definitionSource struct {
+	logger     *logger.Logger
+	downloader *downloader.Downloader
+	def        *Definition
+}
+
+func (s *definitionSource) Releases() ([]Release, error) {
+	s.logger.Debug("Fetching %s versions from: %s", s.def.Name, s.def.Index)
+
+	resp, err := s.downloader.GetHTML(s.def.Index)
+	if err != nil {
+		return nil, errors.NewAPIError(fmt.Sprintf("failed to fetch %s versions", s.def.Name), err)
+	}
+	defer resp.Close()
+
+	data, err := io.ReadAll(resp)
+	if err != nil {
+		return nil, errors.NewAPIError(fmt.Sprintf("failed to read %s versions", s.def.Name), err)
+	}
+
+	candidates, err := s.def.Versions(data)
+	if err != nil {
+		return nil, errors.NewAPIError(fmt.Sprintf("failed to parse %s versions", s.def.Name), err)
+	}
+
+	var releases []Release
+	for _, version := range FinalReleases(candidates) {
+		releases = append(releases, Release{Version: version, URL: s.def.DownloadURL(version)})
+	}
+	return releases, nil
+}
+
+// Versions extracts the versions listed by an index document.
+func (d *Definition) Versions(data []byte) ([]string, error) {
+	var candidates []string
+	if d.VersionPath != "" {
+		var document interface{}
+		if err := json.Unmarshal(data, &document); err != nil {
+			return nil, fmt.Errorf("index is not JSON: %w", err)
+		}
+		steps, err := parseJSONPath(d.VersionPath)
+		if err != nil {
+			return nil, err
+		}
+		candidates = selectJSONPath(document, steps)
+		if d.versionRegex != nil {
+			var matched []string
+			for _, candidate := range candidates {
+				if match := d.versionRegex.FindStringSubmatch(candidate); match != nil {
+					matched = append(matched, firstGroup(match))
+				}
+			}
+			candidates = matched
+		}
+	} else {
+		for _, match := range d.versionRegex.FindAllStringSubmatch(string(data), -1) {
+			candidates = append(candidates, firstGroup(match))
+		}
+	}
+
+	for i := range candidates {
+		candidates[i] = strings.TrimPrefix(strings.TrimSpace(candidates[i]), "v")
+	}
+	return candidates, nil
+}
+
+func firstGroup(match []string) string {
+	if len(match) > 1 {
+		return match[1]
+	}
+	return match[0]
+}
+
+func archiveFromURL(url string) string {
+	switch {
+	case strings.HasSuffix(url, ".tar.gz"), strings.HasSuffix(url, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(url, ".zip"):
+		return "zip"
+	default:
+		return "binary"
+	}
+}
+
+// jsonPathStep is a member name, an array index, or a wildcard when both are
+// unset.
+type jsonPathStep struct {
+	key      string
+	index    int
+	wildcard bool
+}
+
+// parseJSONPath accepts the JSONPath subset "$", ".name", "['name']", "[n]"
+// and "[*]", e.g. "$[*].tag_name" or "$.versions[*].id".
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	rest := strings.TrimSpace(path)
+	if !strings.HasPrefix(rest, "$") {
+		return nil, fmt.Errorf("%q must start with $", path)
+	}
+	rest = rest[1:]
+
+	var steps []jsonPathStep
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			if rest == "" || rest[0] == '.' {
+				return nil, fmt.Errorf("%q: recursive descent is not supported", path)
+			}
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if rest[:end] == "*" {
+				steps = append(steps, jsonPathStep{wildcard: true})
+			} else {
+				steps = append(steps, jsonPathStep{key: rest[:end], index: -1})
+			}
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%q: unterminated [", path)
+			}
+			selector := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case selector == "*":
+				steps = append(steps, jsonPathStep{wildcard: true})
+			case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+				steps = append(steps, jsonPathStep{key: selector[1 : len(selector)-1], index: -1})
+			default:
+				index, err := strconv.Atoi(selector)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("%q: unsupported selector [%s]", path, selector)
+				}
+				steps = append(steps, jsonPathStep{index: index})
+			}
+		default:
+			return nil, fmt.Errorf("%q: unexpected %q", path, rest[0])
+		}
+	}
+	return steps, nil
+}
+
+// selectJSONPath returns the scalar values steps select from document.
+func selectJSONPath(document interface{}, steps []jsonPathStep) []string {
+	nodes := []interface{}{document}
+	for _, step := range steps {
+		var next []interface{}
+		for _, node := range nodes {
+			switch value := node.(type) {
+			case map[string]interface{}:
+				if step.wildcard {
+					keys := make([]string, 0, len(value))
+					for key := range value {
+						keys = append(keys, key)
+					}
+					sort.Strings(keys)
+					for _, key := range keys {
+						next = append(next, value[key])
+					}
+				} else if child, ok := value[step.key]; ok && step.key != "" {
+					next = append(next, child)
+				}
+			case []interface{}:
+				if step.wildcard {
+					next = append(next, value...)
+				} else if step.key == "" && step.index < len(value) {
+					next = append(next, value[step.index])
+				}
+			}
+		}
+		nodes = next
+	}
+
+	var values []string
+	for _, node := range nodes {
+		switch value := node.(type) {
+		case string:
+			values = append(values, value)
+		case float64:
+			values = append(values, strconv.FormatFloat(value, 'f', -1, 64))
+		}
+	}
+	return values
+}
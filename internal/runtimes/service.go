@@ -0,0 +1,434 @@
+package runtimes
+
+import (
+	"aem/internal/platform"
+	"aem/pkg/archiver"
+	"aem/pkg/downloader"
+	"aem/pkg/errors"
+	"aem/pkg/filesystem"
+	"aem/pkg/logger"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// Release is a version of a runtime, with a "v" prefix, and the archive it is
+// installed from on this platform.
+type Release struct {
+	Version string `json:"version"`
+	URL     string `json:"url"`
+}
+
+// Source lists the releases of a runtime that have a build for this platform.
+type Source interface {
+	Releases() ([]Release, error)
+}
+
+// PagedSource is a Source whose history is listed a page at a time, newest
+// page first. Releases returns the first page, and Resolve reads older pages
+// until one has a match.
+type PagedSource interface {
+	Source
+	// Page returns the releases of page n, counting from 1, and whether an
+	// older page follows.
+	Page(n int) ([]Release, bool, error)
+}
+
+// Layout describes where a runtime is installed and how its archives unpack.
+type Layout struct {
+	// Name is the module, also the directory versions are installed in.
+	Name string
+	// Title names the runtime in messages, e.g. "Gradle".
+	Title string
+	// Archive is tar.gz, zip or binary. Empty picks it from the download URL.
+	Archive         string
+	StripComponents int
+	// Probe is the path, relative to an install, that must exist for it to be
+	// considered complete. A binary download is placed in this directory.
+	Probe string
+	// Platform names the build the source picks, e.g. "linux-x64", and is
+	// reported when no release matches.
+	Platform string
+	// Origin is the file the layout was read from, named when an archive does
+	// not match it.
+	Origin string
+}
+
+// Service installs and switches the versions of a runtime, downloading the
+// releases its Source lists. The Gradle, Ruby and Python services and the
+// runtimes defined in AEM_HOME/runtimes all use it.
+type Service struct {
+	logger     *logger.Logger
+	downloader *downloader.Downloader
+	fs         *filesystem.FileSystem
+	zipper     *archiver.ZipExtractor
+	tarGz      *archiver.TarGzExtractor
+	installDir string
+	layout     Layout
+	source     Source
+	def        *Definition
+}
+
+// New returns a service installing the releases of source as laid out by
+// layout.
+func New(logger *logger.Logger, installDir string, layout Layout, source Source) *Service {
+	return &Service{
+		logger:     logger,
+		downloader: downloader.New(logger),
+		fs:         filesystem.New(logger),
+		zipper:     archiver.NewZipExtractor(logger),
+		tarGz:      archiver.NewTarGzExtractor(logger),
+		installDir: installDir,
+		layout:     layout,
+		source:     source,
+	}
+}
+
+// NewService returns the service of a runtime defined in AEM_HOME/runtimes.
+func NewService(logger *logger.Logger, installDir string, def *Definition) *Service {
+	s := New(logger, installDir, def.layout(), &definitionSource{
+		logger:     logger,
+		downloader: downloader.New(logger),
+		def:        def,
+	})
+	s.def = def
+	return s
+}
+
+// Definition returns the definition of the runtime, nil for a built-in one.
+func (s *Service) Definition() *Definition {
+	return s.def
+}
+
+func (s *Service) moduleDir() string {
+	return filepath.Join(s.installDir, s.layout.Name)
+}
+
+func (s *Service) Install(version string) (string, error) {
+	s.logger.Debug("Installing %s version: %s", s.layout.Title, version)
+
+	// Runtimes linked with 'aem link' are selected by name
+	if name, ok := s.fs.ExternalName(s.moduleDir(), version); ok {
+		return name, nil
+	}
+
+	// Check if already installed
+	version = strings.TrimPrefix(version, "v")
+	versionPath := filepath.Join(s.moduleDir(), "v"+version)
+	if s.fs.InstallComplete(versionPath, s.layout.Probe) {
+		s.logger.Debug("%s version %s already installed", s.layout.Title, version)
+		return "v" + version, nil
+	}
+
+	release, err := s.resolveRelease(version)
+	if err != nil {
+		return "", err
+	}
+
+	// Resolved before locking, so a slow mirror does not hold up other aem
+	// processes
+	unlock, err := s.fs.Lock()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	finalPath := filepath.Join(s.moduleDir(), release.Version)
+	if s.fs.InstallComplete(finalPath, s.layout.Probe) {
+		s.fs.MarkInstallComplete(finalPath)
+		s.logger.Debug("%s version %s already installed", s.layout.Title, release.Version)
+		return release.Version, nil
+	}
+
+	// Download and install
+	if err := s.downloadAndInstall(release.URL, finalPath); err != nil {
+		return "", err
+	}
+
+	s.logger.Debug("Successfully installed %s version: %s", s.layout.Title, release.Version)
+	return release.Version, nil
+}
+
+func (s *Service) Use(version string, symlinkPath string) error {
+	s.logger.Debug("Setting %s version: %s", s.layout.Title, version)
+
+	if name, ok := s.fs.ExternalName(s.moduleDir(), version); ok {
+		version = name
+	}
+
+	versionPath := filepath.Join(s.moduleDir(), version)
+	if !s.fs.Exists(versionPath) {
+		vVersionPath := filepath.Join(s.moduleDir(), "v"+version)
+		if !s.fs.Exists(vVersionPath) {
+			return errors.NewValidationError(fmt.Sprintf("%s version not installed: %s", s.layout.Title, version))
+		}
+		versionPath = vVersionPath
+	}
+
+	if symlinkPath == "" {
+		return errors.NewValidationError("symlink path not configured")
+	}
+
+	if err := s.fs.CreateSymlink(symlinkPath, versionPath); err != nil {
+		return err
+	}
+
+	s.logger.Debug("Successfully set %s version: %s", s.layout.Title, version)
+	return nil
+}
+
+// SymlinkPath returns AEM_HOME/current/<name>, the active link of the runtime.
+func (s *Service) SymlinkPath() (string, error) {
+	currentRoot, err := s.fs.GetCurrentRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(currentRoot, s.layout.Name), nil
+}
+
+// Releases returns the releases of the source, oldest first.
+func (s *Service) Releases() ([]Release, error) {
+	releases, err := s.source.Releases()
+	if err != nil {
+		return nil, err
+	}
+	sortReleases(releases)
+	return releases, nil
+}
+
+// FinalReleases sorts candidates oldest first with a "v" prefix, dropping
+// duplicates, prereleases and versions not of the form 1, 1.2 or 1.2.3.
+func FinalReleases(candidates []string) []string {
+	seen := make(map[string]bool, len(candidates))
+	var versions []string
+	for _, candidate := range candidates {
+		version := "v" + strings.TrimPrefix(candidate, "v")
+		if !semver.IsValid(version) || semver.Prerelease(version) != "" || seen[version] {
+			continue
+		}
+		seen[version] = true
+		versions = append(versions, version)
+	}
+	sortVersions(versions)
+	return versions
+}
+
+// Resolve returns the newest release matching version, e.g. "v3.9.6" for "3".
+func (s *Service) Resolve(version string) (string, error) {
+	release, err := s.resolveRelease(version)
+	if err != nil {
+		return "", err
+	}
+	return release.Version, nil
+}
+
+// LatestLTS returns the newest release. Only Node.js and Java have LTS lines,
+// the runtimes installed here maintain their newest versions instead.
+func (s *Service) LatestLTS() (string, error) {
+	releases, err := s.Releases()
+	if err != nil {
+		return "", err
+	}
+	if len(releases) == 0 {
+		return "", errors.NewAPIError(fmt.Sprintf("no %s release found%s", s.layout.Title, s.onPlatform()), nil)
+	}
+	return releases[len(releases)-1].Version, nil
+}
+
+func (s *Service) resolveRelease(version string) (Release, error) {
+	version = "v" + strings.TrimPrefix(version, "v")
+
+	for n := 1; ; n++ {
+		releases, more, err := s.page(n)
+		if err != nil {
+			return Release{}, err
+		}
+
+		for i := len(releases) - 1; i >= 0; i-- {
+			if releases[i].Version == version || strings.HasPrefix(releases[i].Version, version+".") {
+				return releases[i], nil
+			}
+		}
+
+		// Older pages cannot hold a version newer than this one does
+		if !more || len(releases) == 0 || semver.Compare(version, releases[len(releases)-1].Version) > 0 {
+			break
+		}
+	}
+	return Release{}, errors.NewValidationError(fmt.Sprintf("no %s releases found for version %s%s", s.layout.Title, strings.TrimPrefix(version, "v"), s.onPlatform()))
+}
+
+// page returns page n of a PagedSource, or every release as a single page.
+func (s *Service) page(n int) ([]Release, bool, error) {
+	paged, ok := s.source.(PagedSource)
+	if !ok {
+		releases, err := s.Releases()
+		return releases, false, err
+	}
+
+	releases, more, err := paged.Page(n)
+	if err != nil {
+		return nil, false, err
+	}
+	sortReleases(releases)
+	return releases, more, nil
+}
+
+func (s *Service) onPlatform() string {
+	if s.layout.Platform == "" {
+		return ""
+	}
+	return " on " + s.layout.Platform
+}
+
+func (s *Service) downloadAndInstall(url, finalPath string) error {
+	stagingDir, err := s.fs.NewStagingDir(s.layout.Name)
+	if err != nil {
+		return err
+	}
+	defer s.fs.RemoveAll(stagingDir)
+
+	archivePath := filepath.Join(stagingDir, path.Base(url))
+	extractDir := filepath.Join(stagingDir, "extract")
+
+	// Download
+	if err := s.downloader.Download(url, archivePath); err != nil {
+		return err
+	}
+
+	// Extract
+	archive := s.layout.Archive
+	if archive == "" {
+		archive = archiveFromURL(url)
+	}
+	switch archive {
+	case "zip":
+		if err := s.zipper.Extract(archivePath, extractDir); err != nil {
+			return err
+		}
+	case "tar.gz":
+		if err := s.tarGz.Extract(archivePath, extractDir); err != nil {
+			return err
+		}
+	default:
+		// A bare executable is placed in the probed directory
+		name := s.layout.Name
+		if platform.GetInfo().OS == "windows" {
+			name += ".exe"
+		}
+		binDir := filepath.Join(extractDir, s.layout.Probe)
+		if err := s.fs.EnsureDir(binDir); err != nil {
+			return err
+		}
+		if err := os.Rename(archivePath, filepath.Join(binDir, name)); err != nil {
+			return errors.NewFileSystemError("failed to move "+archivePath, err)
+		}
+		if err := os.Chmod(filepath.Join(binDir, name), 0755); err != nil {
+			return errors.NewFileSystemError("failed to make "+name+" executable", err)
+		}
+	}
+
+	// Strip leading directories, like tar --strip-components
+	root := extractDir
+	for i := 0; i < s.layout.StripComponents; i++ {
+		entries, err := s.fs.ListDir(root)
+		if err != nil {
+			return err
+		}
+		if len(entries) != 1 || !entries[0].IsDir() {
+			return errors.NewExtractionError(fmt.Sprintf("cannot strip %d components: expected a single directory in %s archive", s.layout.StripComponents, s.layout.Title), nil)
+		}
+		root = filepath.Join(root, entries[0].Name())
+	}
+
+	if !s.fs.Exists(filepath.Join(root, s.layout.Probe)) {
+		message := fmt.Sprintf("%s archive has no %s", s.layout.Title, s.layout.Probe)
+		if s.layout.Origin != "" {
+			message += ", check stripComponents and bin in " + s.layout.Origin
+		}
+		return errors.NewExtractionError(message, nil)
+	}
+
+	// Move to final location
+	return s.fs.CommitInstall(root, finalPath)
+}
+
+func (s *Service) GetCurrentVersion() (string, error) {
+	state, err := s.fs.GetState()
+	if err != nil {
+		return "", err
+	}
+	return state.CurrentVersion(s.layout.Name)
+}
+
+// CanUninstall returns an UninstallError when version is the active version
+// or an external runtime, which must never be removed.
+func (s *Service) CanUninstall(version string) error {
+	currentVersion, err := s.GetCurrentVersion()
+	if err != nil {
+		return err
+	}
+
+	if currentVersion != "" && currentVersion == strings.TrimPrefix(version, "v") {
+		return errors.UninstallError(fmt.Sprintf("cannot uninstall version %s as it's the currently active version", version), nil)
+	}
+
+	if name, ok := s.fs.ExternalName(s.moduleDir(), version); ok {
+		return errors.UninstallError(fmt.Sprintf("cannot uninstall %s as it's an external runtime, run 'aem link --remove %s %s' to unregister it", name, s.layout.Name, name), nil)
+	}
+	return nil
+}
+
+func (s *Service) Uninstall(version string) error {
+	s.logger.Debug("Un-installing %s version: %s", s.layout.Title, version)
+
+	unlock, err := s.fs.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.CanUninstall(version); err != nil {
+		return err
+	}
+
+	versionPath := filepath.Join(s.moduleDir(), version)
+	if !s.fs.Exists(versionPath) {
+		vVersionPath := filepath.Join(s.moduleDir(), "v"+version)
+		if s.fs.Exists(vVersionPath) {
+			versionPath = vVersionPath
+		} else {
+			s.logger.Debug("%s version %s not found", s.layout.Title, version)
+			return nil
+		}
+	}
+
+	s.logger.Debug("Removing %s version %s from %s", s.layout.Title, version, versionPath)
+	if err := s.fs.RemoveAll(versionPath); err != nil {
+		return fmt.Errorf("failed to remove %s version %s: %w", s.layout.Title, version, err)
+	}
+
+	s.logger.Debug("Successfully removed %s version %s", s.layout.Title, version)
+	return nil
+}
+
+func sortReleases(releases []Release) {
+	sort.SliceStable(releases, func(i, j int) bool {
+		return semver.Compare(releases[i].Version, releases[j].Version) < 0
+	})
+}
+
+func sortVersions(versions []string) {
+	sort.Slice(versions, func(i, j int) bool {
+		left, right := versions[i], versions[j]
+		if semver.IsValid(left) && semver.IsValid(right) {
+			return semver.Compare(left, right) < 0
+		}
+		return left < right
+	})
+}
@@ -3,80 +3,189 @@ package setup
 import (
 	"aem/internal/android"
 	"aem/internal/config"
+	"aem/internal/gradle"
 	"aem/internal/java"
 	"aem/internal/node"
+	"aem/internal/python"
+	"aem/internal/ruby"
+	"aem/internal/runtimes"
+	"aem/pkg/errors"
+	"aem/pkg/filesystem"
 	"aem/pkg/logger"
+	"aem/pkg/settings"
+	"aem/pkg/state"
 	"fmt"
-	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 )
 
 type Service struct {
-	logger *logger.Logger
-	node   *node.Service
-	java   *java.Service
-	android *android.Service
+	logger     *logger.Logger
+	node       *node.Service
+	java       *java.Service
+	gradle     *gradle.Service
+	ruby       *ruby.Service
+	python     *python.Service
+	android    *android.Service
+	fs         *filesystem.FileSystem
+	installDir string
+	settings   *settings.Settings
+	origin     state.Origin
+	originMu   sync.Mutex
+	selected   []Selection
+}
+
+// Selection is a runtime version a setup run activated.
+type Selection struct {
+	Module  string `json:"module" yaml:"module"`
+	Version string `json:"version" yaml:"version"`
+}
+
+// Result describes what a setup run activated and from which config.
+type Result struct {
+	Config   string      `json:"config,omitempty" yaml:"config,omitempty"`
+	Profile  string      `json:"profile,omitempty" yaml:"profile,omitempty"`
+	Runtimes []Selection `json:"runtimes" yaml:"runtimes"`
 }
 
 func NewService(logger *logger.Logger, installDir string) *Service {
 	return &Service{
-		logger:  logger,
-		node:    node.NewService(logger, installDir),
-		java:    java.NewService(logger, installDir),
-		android: android.NewService(logger, installDir),
+		logger:     logger,
+		node:       node.NewService(logger, installDir),
+		java:       java.NewService(logger, installDir),
+		gradle:     gradle.NewService(logger, installDir),
+		ruby:       ruby.NewService(logger, installDir),
+		python:     python.NewService(logger, installDir),
+		android:    android.NewService(logger, installDir),
+		fs:         filesystem.New(logger),
+		installDir: installDir,
 	}
 }
 
-func (s *Service) Setup() error {
+func (s *Service) Setup(profile string) (*Result, error) {
 	s.logger.Info("Starting environment setup")
 
-	configPath, err := config.FindProjectConfig("")
+	cfg, err := settings.Load()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	s.logger.Debug("Using project config: %s", configPath)
+	s.settings = cfg
 
-	projectConfig, err := config.LoadProjectConfig(configPath)
+	// Hold the lock for the whole run so that a parallel setup cannot switch
+	// links in between the runtimes installed here.
+	unlock, err := s.fs.Lock()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer unlock()
+
+	projectConfig, err := s.resolveConfig(profile)
+	if err != nil {
+		return nil, err
 	}
 
 	javaHome, err := s.setupCoreRuntimes(projectConfig)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if err := s.setupAndroid(projectConfig.Android, javaHome); err != nil {
-		return err
+		return nil, err
 	}
 
 	s.logger.Info("Environment setup completed successfully")
-	return nil
+
+	sort.Slice(s.selected, func(i, j int) bool {
+		return s.selected[i].Module < s.selected[j].Module
+	})
+	return &Result{Config: s.origin.Config, Profile: profile, Runtimes: append([]Selection{}, s.selected...)}, nil
+}
+
+// resolveConfig loads the project config, falling back to the user-level
+// defaults when the working directory is not inside a project.
+func (s *Service) resolveConfig(profile string) (*config.ProjectConfig, error) {
+	if _, err := config.FindProjectConfig(""); err != nil {
+		defaults, ok := config.DefaultProjectConfig(s.settings)
+		if !ok {
+			return nil, errors.NewValidationError(err.Error() + "; run 'aem default <module> <version>' to set versions used outside a project")
+		}
+		s.logger.Info("No project config found, using default versions")
+		s.origin = state.Origin{Source: state.OriginDefault}
+		return defaults, nil
+	}
+
+	resolved, err := config.ResolveProjectConfig("")
+	if err != nil {
+		return nil, err
+	}
+	for _, configPath := range resolved.Files {
+		s.logger.Debug("Using project config: %s", configPath)
+	}
+
+	projectConfig, err := resolved.Config.WithProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+	if profile != "" {
+		s.logger.Info("Using profile: %s", profile)
+	}
+	if projectConfig.PackageManager == "" {
+		projectConfig.PackageManager = config.PackageJSONPackageManager(resolved.Dir())
+	}
+
+	// Floating specs use the versions 'aem upgrade --lock' recorded for them
+	locked, err := config.LoadLock(config.LockPath(resolved.Path()))
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range projectConfig.ApplyLock(locked) {
+		s.logger.Debug("Using %s %s from %s", field, locked[field], config.LockPath(resolved.Path()))
+	}
+
+	s.origin = state.Origin{Source: state.OriginProject, Config: resolved.Path()}
+	return projectConfig, nil
 }
 
 func (s *Service) setupCoreRuntimes(projectConfig *config.ProjectConfig) (string, error) {
+	tools, err := s.toolServices(projectConfig.Tools)
+	if err != nil {
+		return "", err
+	}
+
 	var wg sync.WaitGroup
+	slots := make(chan struct{}, s.settings.Parallelism())
 
 	nodeErrCh := make(chan error, 1)
 	javaErrCh := make(chan error, 1)
+	gradleErrCh := make(chan error, 1)
+	rubyErrCh := make(chan error, 1)
+	pythonErrCh := make(chan error, 1)
+	toolErrCh := make(chan error, len(tools))
 	javaHomeCh := make(chan string, 1)
 
 	if projectConfig.Node != "" {
 		wg.Add(1)
 		go func(version string) {
 			defer wg.Done()
-			nodeErrCh <- s.setupNode(version)
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			nodeErrCh <- s.setupNode(version, projectConfig.PackageManager, projectConfig.GlobalPackages)
 		}(projectConfig.Node)
 	} else {
 		s.logger.Debug("No Node.js version specified in config")
+		if projectConfig.PackageManager != "" {
+			s.logger.Info("packageManager %s ignored: no Node.js version specified in config", projectConfig.PackageManager)
+		}
 	}
 
 	if projectConfig.JDK != "" {
 		wg.Add(1)
 		go func(version string) {
 			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
 			javaHome, err := s.setupJava(version)
 			if err != nil {
 				javaErrCh <- err
@@ -89,9 +198,59 @@ func (s *Service) setupCoreRuntimes(projectConfig *config.ProjectConfig) (string
 		s.logger.Debug("No JDK version specified in config")
 	}
 
+	if projectConfig.Gradle != "" {
+		wg.Add(1)
+		go func(version string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			gradleErrCh <- s.setupGradle(version)
+		}(projectConfig.Gradle)
+	} else {
+		s.logger.Debug("No Gradle version specified in config")
+	}
+
+	if projectConfig.Ruby != "" {
+		wg.Add(1)
+		go func(version string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			rubyErrCh <- s.setupRuby(version)
+		}(projectConfig.Ruby)
+	} else {
+		s.logger.Debug("No Ruby version specified in config")
+	}
+
+	if projectConfig.Python != "" {
+		wg.Add(1)
+		go func(version string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			pythonErrCh <- s.setupPython(version)
+		}(projectConfig.Python)
+	} else {
+		s.logger.Debug("No Python version specified in config")
+	}
+
+	for name, service := range tools {
+		wg.Add(1)
+		go func(service *runtimes.Service, version string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			toolErrCh <- s.setupTool(service, version)
+		}(service, projectConfig.Tools[name])
+	}
+
 	wg.Wait()
 	close(nodeErrCh)
 	close(javaErrCh)
+	close(gradleErrCh)
+	close(rubyErrCh)
+	close(pythonErrCh)
+	close(toolErrCh)
 	close(javaHomeCh)
 
 	for err := range nodeErrCh {
@@ -106,6 +265,30 @@ func (s *Service) setupCoreRuntimes(projectConfig *config.ProjectConfig) (string
 		}
 	}
 
+	for err := range gradleErrCh {
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for err := range rubyErrCh {
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for err := range pythonErrCh {
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for err := range toolErrCh {
+		if err != nil {
+			return "", err
+		}
+	}
+
 	for javaHome := range javaHomeCh {
 		return javaHome, nil
 	}
@@ -113,15 +296,24 @@ func (s *Service) setupCoreRuntimes(projectConfig *config.ProjectConfig) (string
 	return "", nil
 }
 
-func (s *Service) setupNode(version string) error {
+func (s *Service) setupNode(version, packageManager string, globalPackages []string) error {
 	s.logger.Debug("Setting up Node.js version: %s", version)
 
+	var pm node.PackageManager
+	if packageManager != "" {
+		var err error
+		if pm, err = node.ParsePackageManager(packageManager); err != nil {
+			return err
+		}
+	}
+
 	// Normalize version format
 	if !strings.HasPrefix(version, "v") {
 		version = "v" + version
 	}
 
-	// Install Node.js
+	// Install Node.js, together with the default and project global packages
+	s.node.AddGlobalPackages(globalPackages)
 	lastestNodeVersion, err := s.node.Install(version)
 	if err != nil {
 		return fmt.Errorf("failed to install Node.js: %w", err)
@@ -131,15 +323,19 @@ func (s *Service) setupNode(version string) error {
 		return fmt.Errorf("failed to find installed version for %s", version)
 	}
 
-	symlinkPath, err := resolveSymlinkPath("AEM_NODE_SYMLINK", "current", "node")
-	if err != nil {
-		return err
-	}
+	symlinkPath := s.settings.SymlinkPath("node")
 
 	if err := s.node.Use(lastestNodeVersion, symlinkPath); err != nil {
 		return fmt.Errorf("failed to set Node.js version: %w", err)
 	}
 
+	if pm.Spec != "" {
+		if err := s.node.InstallPackageManager(lastestNodeVersion, pm); err != nil {
+			return err
+		}
+	}
+
+	s.recordOrigin("node", lastestNodeVersion)
 	return nil
 }
 
@@ -155,18 +351,123 @@ func (s *Service) setupJava(version string) (string, error) {
 		return "", fmt.Errorf("failed to find installed version for %s", version)
 	}
 
-	symlinkPath, err := resolveSymlinkPath("AEM_JAVA_SYMLINK", "current", "java")
-	if err != nil {
-		return "", err
-	}
+	symlinkPath := s.settings.SymlinkPath("java")
 
 	if err := s.java.Use(lastestJdkVersion, symlinkPath); err != nil {
 		return "", fmt.Errorf("failed to set JDK version: %w", err)
 	}
 
+	s.recordOrigin("java", lastestJdkVersion)
+
 	return filepath.Clean(symlinkPath), nil
 }
 
+func (s *Service) setupGradle(version string) error {
+	s.logger.Debug("Setting up Gradle version: %s", version)
+
+	installedVersion, err := s.gradle.Install(version)
+	if err != nil {
+		return fmt.Errorf("failed to install Gradle: %w", err)
+	}
+
+	symlinkPath := s.settings.SymlinkPath("gradle")
+
+	if err := s.gradle.Use(installedVersion, symlinkPath); err != nil {
+		return fmt.Errorf("failed to set Gradle version: %w", err)
+	}
+
+	s.recordOrigin("gradle", installedVersion)
+	return nil
+}
+
+func (s *Service) setupRuby(version string) error {
+	s.logger.Debug("Setting up Ruby version: %s", version)
+
+	installedVersion, err := s.ruby.Install(version)
+	if err != nil {
+		return fmt.Errorf("failed to install Ruby: %w", err)
+	}
+
+	symlinkPath := s.settings.SymlinkPath("ruby")
+
+	if err := s.ruby.Use(installedVersion, symlinkPath); err != nil {
+		return fmt.Errorf("failed to set Ruby version: %w", err)
+	}
+
+	s.recordOrigin("ruby", installedVersion)
+	return nil
+}
+
+func (s *Service) setupPython(version string) error {
+	s.logger.Debug("Setting up Python version: %s", version)
+
+	installedVersion, err := s.python.Install(version)
+	if err != nil {
+		return fmt.Errorf("failed to install Python: %w", err)
+	}
+
+	symlinkPath := s.settings.SymlinkPath("python")
+
+	if err := s.python.Use(installedVersion, symlinkPath); err != nil {
+		return fmt.Errorf("failed to set Python version: %w", err)
+	}
+
+	s.recordOrigin("python", installedVersion)
+	return nil
+}
+
+// toolServices returns a service for every entry of the tools field, failing
+// before anything is installed when one has no definition in AEM_HOME/runtimes.
+func (s *Service) toolServices(tools map[string]string) (map[string]*runtimes.Service, error) {
+	services := make(map[string]*runtimes.Service, len(tools))
+	if len(tools) == 0 {
+		return services, nil
+	}
+
+	aemHome, err := s.fs.GetAEMHome()
+	if err != nil {
+		return nil, err
+	}
+	defs, err := runtimes.Load(aemHome)
+	if err != nil {
+		return nil, err
+	}
+
+	for name := range tools {
+		for _, def := range defs {
+			if def.Name == name {
+				services[name] = runtimes.NewService(s.logger, s.installDir, def)
+			}
+		}
+		if services[name] == nil {
+			return nil, errors.NewValidationError(fmt.Sprintf("tool %q has no definition, add %s", name, filepath.Join(aemHome, runtimes.Dir, name+".json")))
+		}
+	}
+	return services, nil
+}
+
+func (s *Service) setupTool(service *runtimes.Service, version string) error {
+	name := service.Definition().Name
+	s.logger.Debug("Setting up %s version: %s", name, version)
+
+	installedVersion, err := service.Install(version)
+	if err != nil {
+		return fmt.Errorf("failed to install %s: %w", name, err)
+	}
+
+	symlinkPath, err := service.SymlinkPath()
+	if err != nil {
+		return err
+	}
+
+	if err := service.Use(installedVersion, symlinkPath); err != nil {
+		return fmt.Errorf("failed to set %s version: %w", name, err)
+	}
+
+	s.recordOrigin(name, installedVersion)
+	return nil
+}
+
 func (s *Service) setupAndroid(cfg config.AndroidConfig, javaHome string) error {
 	if len(cfg.SDK) == 0 && len(cfg.NDK) == 0 && len(cfg.BuildTool) == 0 {
 		s.logger.Debug("No Android SDK configuration specified in config")
@@ -178,10 +479,7 @@ func (s *Service) setupAndroid(cfg config.AndroidConfig, javaHome string) error
 		return err
 	}
 
-	symlinkPath, err := resolveSymlinkPath("AEM_ANDROID_SYMLINK", "current", "android")
-	if err != nil {
-		return err
-	}
+	symlinkPath := s.settings.SymlinkPath("android")
 
 	if err := s.android.Use(symlinkPath); err != nil {
 		return fmt.Errorf("failed to set Android SDK path: %w", err)
@@ -190,23 +488,25 @@ func (s *Service) setupAndroid(cfg config.AndroidConfig, javaHome string) error
 	return nil
 }
 
-func resolveSymlinkPath(envName string, defaults ...string) (string, error) {
-	if value := strings.TrimSpace(getEnv(envName)); value != "" {
-		return value, nil
-	}
-
-	aemHome := strings.TrimSpace(getEnv("AEM_HOME"))
-	if aemHome == "" {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return "", fmt.Errorf("%s and AEM_HOME are not configured", envName)
+// recordOrigin remembers whether the active version came from the project or
+// the defaults so that "aem current" can report it, and which project uses
+// it so that "aem gc" keeps it. Failures are not fatal.
+func (s *Service) recordOrigin(module, version string) {
+	s.originMu.Lock()
+	defer s.originMu.Unlock()
+
+	s.selected = append(s.selected, Selection{Module: module, Version: strings.TrimPrefix(version, "v")})
+
+	st, err := s.fs.GetState()
+	if err == nil {
+		origin := s.origin
+		origin.Version = version
+		err = st.SetOrigin(module, origin)
+		if err == nil {
+			err = st.RecordUsage(module, version, origin.Config)
 		}
-		aemHome = filepath.Join(homeDir, ".aem")
 	}
-
-	return filepath.Join(append([]string{aemHome}, defaults...)...), nil
-}
-
-var getEnv = func(key string) string {
-	return os.Getenv(key)
+	if err != nil {
+		s.logger.Debug("Failed to record %s origin: %v", module, err)
+	}
 }
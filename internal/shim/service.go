@@ -0,0 +1,331 @@
+package shim
+
+import (
+	"aem/internal/config"
+	"aem/internal/environment"
+	"aem/internal/platform"
+	"aem/internal/runtimes"
+	"aem/pkg/errors"
+	"aem/pkg/filesystem"
+	"aem/pkg/logger"
+	"aem/pkg/settings"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Modules lists the runtimes shims are generated for, in lookup order.
+var Modules = []string{"node", "java", "gradle", "ruby", "python", "android"}
+
+// defaultTools always get a shim, even before a runtime providing them is
+// installed, so that PATH lookups never fall through to a system install.
+var defaultTools = map[string]string{
+	"node":    "node",
+	"npm":     "node",
+	"npx":     "node",
+	"java":    "java",
+	"javac":   "java",
+	"gradle":  "gradle",
+	"ruby":    "ruby",
+	"gem":     "ruby",
+	"bundle":  "ruby",
+	"python3": "python",
+	"pip3":    "python",
+	"adb":     "android",
+}
+
+var windowsExecutableExts = []string{".exe", ".cmd", ".bat"}
+
+type Service struct {
+	logger     *logger.Logger
+	fs         *filesystem.FileSystem
+	installDir string
+}
+
+func NewService(logger *logger.Logger, installDir string) *Service {
+	return &Service{
+		logger:     logger,
+		fs:         filesystem.New(logger),
+		installDir: installDir,
+	}
+}
+
+// Dir returns AEM_HOME/shims.
+func (s *Service) Dir() (string, error) {
+	aemHome, err := s.fs.GetAEMHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(aemHome, "shims"), nil
+}
+
+// Reshim regenerates a shim for every executable of every installed runtime
+// version and removes shims for tools that are no longer provided.
+func (s *Service) Reshim() ([]string, error) {
+	aemPath, err := os.Executable()
+	if err != nil {
+		return nil, errors.NewFileSystemError("failed to locate the aem executable", err)
+	}
+
+	shimDir, err := s.Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	aemHome, err := s.fs.GetAEMHome()
+	if err != nil {
+		return nil, err
+	}
+	defs, err := runtimes.Load(aemHome)
+	if err != nil {
+		return nil, err
+	}
+
+	tools := make(map[string]string)
+	for tool, module := range defaultTools {
+		tools[tool] = module
+	}
+	// Tools of built-in modules win over those of defined runtimes
+	for _, def := range defs {
+		for _, root := range s.installedRoots(def.Name, def.Probe()) {
+			for _, tool := range executables(def.BinDirs(root)) {
+				tools[tool] = def.Name
+			}
+		}
+	}
+	for i := len(Modules) - 1; i >= 0; i-- {
+		module := Modules[i]
+		for _, root := range s.installedRoots(module, platform.GetInfo().RuntimeBinary(module)) {
+			for _, tool := range executables(environment.RuntimeBinDirs(runtimeOptions(module, root))) {
+				tools[tool] = module
+			}
+		}
+	}
+
+	unlock, err := s.fs.Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err := s.fs.RemoveAll(shimDir); err != nil {
+		return nil, err
+	}
+	if err := s.fs.EnsureDir(shimDir); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(tools))
+	for tool, module := range tools {
+		if err := writeShim(shimDir, aemPath, module, tool); err != nil {
+			return nil, err
+		}
+		names = append(names, tool)
+	}
+	sort.Strings(names)
+
+	s.logger.Debug("Wrote %d shims to %s", len(names), shimDir)
+	return names, nil
+}
+
+// Resolve returns the environment a shimmed tool of module runs with: the
+// version requested by the nearest project config, else the default version,
+// else the current link.
+func (s *Service) Resolve(module string) (environment.Options, error) {
+	cfg, err := settings.Load()
+	if err != nil {
+		return environment.Options{}, err
+	}
+	aemHome, err := s.fs.GetAEMHome()
+	if err != nil {
+		return environment.Options{}, err
+	}
+	opts := environment.Options{AEMHome: aemHome}
+
+	var def *runtimes.Definition
+	if !containsString(Modules, module) {
+		found, ok, err := runtimes.Find(aemHome, module)
+		if err != nil {
+			return environment.Options{}, err
+		}
+		if !ok {
+			return environment.Options{}, errors.NewValidationError(module + " module does not exist")
+		}
+		def = found
+	}
+
+	var requested, source string
+	if _, err := config.FindProjectConfig(""); err == nil {
+		resolved, err := config.ResolveProjectConfig("")
+		if err != nil {
+			return environment.Options{}, err
+		}
+		projectConfig, err := resolved.Config.WithProfile(config.ResolveProfile(""))
+		if err != nil {
+			return environment.Options{}, err
+		}
+		opts.Project = projectConfig
+		opts.ProjectDir = resolved.Dir()
+
+		requested, source = projectVersion(projectConfig, module), resolved.Path()
+	}
+	if requested == "" && module != "android" {
+		requested, source = cfg.DefaultVersion(module), "the default version"
+	}
+
+	root := cfg.SymlinkPath(module)
+	if def != nil {
+		root = filepath.Join(aemHome, "current", module)
+	}
+	if requested != "" {
+		root = s.fs.LatestInstalled(filepath.Join(s.installDir, module), requested)
+		if root == "" {
+			return environment.Options{}, errors.NewValidationError(fmt.Sprintf("%s %s requested by %s is not installed; run 'aem setup'", module, requested, source))
+		}
+	} else if !s.fs.Exists(root) {
+		return environment.Options{}, errors.NewValidationError(fmt.Sprintf("no %s version is active; run 'aem setup' or 'aem use %s <version>'", module, module))
+	}
+
+	if def != nil {
+		opts.Tools = []environment.ToolHome{def.ToolHome(root)}
+		return opts, nil
+	}
+
+	resolved := runtimeOptions(module, root)
+	opts.NodeHome, opts.JavaHome, opts.GradleHome, opts.RubyHome, opts.PythonHome, opts.AndroidHome = resolved.NodeHome, resolved.JavaHome, resolved.GradleHome, resolved.RubyHome, resolved.PythonHome, resolved.AndroidHome
+	return opts, nil
+}
+
+// Binary finds the real executable of tool in the runtime selected by opts.
+func Binary(opts environment.Options, tool string) (string, error) {
+	for _, dir := range environment.RuntimeBinDirs(opts) {
+		candidates := []string{filepath.Join(dir, tool)}
+		if platform.GetInfo().OS == "windows" {
+			candidates = candidates[:0]
+			for _, ext := range windowsExecutableExts {
+				candidates = append(candidates, filepath.Join(dir, tool+ext))
+			}
+		}
+		for _, candidate := range candidates {
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				return candidate, nil
+			}
+		}
+	}
+	return "", errors.NewValidationError(tool + " is not provided by the selected runtime")
+}
+
+func (s *Service) installedRoots(module, probe string) []string {
+	if module == "android" {
+		return []string{filepath.Join(s.installDir, "android", "sdk")}
+	}
+
+	moduleDir := filepath.Join(s.installDir, module)
+	versions, err := s.fs.InstalledVersions(moduleDir)
+	if err != nil {
+		return nil
+	}
+
+	var roots []string
+	for _, version := range versions {
+		root := filepath.Join(moduleDir, version)
+		if s.fs.InstallComplete(root, probe) {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+func projectVersion(cfg *config.ProjectConfig, module string) string {
+	switch module {
+	case "node":
+		return cfg.Node
+	case "java":
+		return cfg.JDK
+	case "gradle":
+		return cfg.Gradle
+	case "ruby":
+		return cfg.Ruby
+	case "python":
+		return cfg.Python
+	case "android":
+		return ""
+	default:
+		return cfg.Tools[module]
+	}
+}
+
+func runtimeOptions(module, root string) environment.Options {
+	switch module {
+	case "node":
+		return environment.Options{NodeHome: root}
+	case "java":
+		return environment.Options{JavaHome: root}
+	case "gradle":
+		return environment.Options{GradleHome: root}
+	case "ruby":
+		return environment.Options{RubyHome: root}
+	case "python":
+		return environment.Options{PythonHome: root}
+	default:
+		return environment.Options{AndroidHome: root}
+	}
+}
+
+func executables(dirs []string) []string {
+	windows := platform.GetInfo().OS == "windows"
+
+	var tools []string
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			info, err := os.Stat(filepath.Join(dir, entry.Name()))
+			if err != nil || info.IsDir() {
+				continue
+			}
+
+			name := entry.Name()
+			if windows {
+				ext := strings.ToLower(filepath.Ext(name))
+				if !containsString(windowsExecutableExts, ext) {
+					continue
+				}
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			} else if info.Mode()&0111 == 0 {
+				continue
+			}
+			tools = append(tools, name)
+		}
+	}
+	return tools
+}
+
+func writeShim(shimDir, aemPath, module, tool string) error {
+	var path, content string
+	if platform.GetInfo().OS == "windows" {
+		path = filepath.Join(shimDir, tool+".cmd")
+		content = fmt.Sprintf("@echo off\r\n\"%s\" shim-exec %s %s %%*\r\n", aemPath, module, tool)
+	} else {
+		path = filepath.Join(shimDir, tool)
+		content = fmt.Sprintf("#!/bin/sh\nexec '%s' shim-exec %s %s \"$@\"\n", strings.ReplaceAll(aemPath, "'", `'\''`), module, tool)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+		return errors.FileWriteSystemError("failed to write shim "+path, err)
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// cachePath returns where the archive for url is kept in the download cache,
+// or "" when caching is disabled.
+func (d *Downloader) cachePath(url string) string {
+	if d.cacheDir == "" || d.cacheMaxSize <= 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(d.cacheDir, hex.EncodeToString(sum[:8])+"-"+filepath.Base(url))
+}
+
+func (d *Downloader) restoreFromCache(url, destPath string) bool {
+	cached := d.cachePath(url)
+	if cached == "" {
+		return false
+	}
+	if _, err := os.Stat(cached); err != nil {
+		return false
+	}
+
+	if err := linkOrCopy(cached, destPath); err != nil {
+		d.logger.Debug("Failed to restore %s from cache: %v", url, err)
+		return false
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(cached, now, now)
+	d.logger.Debug("Using cached download: %s", cached)
+	return true
+}
+
+func (d *Downloader) storeInCache(url, srcPath string) {
+	cached := d.cachePath(url)
+	if cached == "" {
+		return
+	}
+	if err := os.MkdirAll(d.cacheDir, 0755); err != nil {
+		d.logger.Debug("Failed to create download cache: %v", err)
+		return
+	}
+	if err := linkOrCopy(srcPath, cached); err != nil {
+		d.logger.Debug("Failed to cache %s: %v", url, err)
+		return
+	}
+	d.pruneCache()
+}
+
+// pruneCache removes the least recently used archives until the cache fits
+// within its size limit.
+func (d *Downloader) pruneCache() {
+	entries, err := os.ReadDir(d.cacheDir)
+	if err != nil {
+		return
+	}
+
+	type cachedFile struct {
+		path string
+		size int64
+		used int64
+	}
+
+	var files []cachedFile
+	var total int64
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cachedFile{
+			path: filepath.Join(d.cacheDir, entry.Name()),
+			size: info.Size(),
+			used: info.ModTime().UnixNano(),
+		})
+		total += info.Size()
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].used < files[j].used
+	})
+
+	for _, file := range files {
+		if total <= d.cacheMaxSize {
+			return
+		}
+		if err := os.Remove(file.path); err == nil {
+			d.logger.Debug("Evicted cached download: %s", file.path)
+			total -= file.size
+		}
+	}
+}
+
+func linkOrCopy(src, dst string) error {
+	_ = os.Remove(dst)
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
@@ -5,25 +5,61 @@ import (
 	"aem/pkg/logger"
 	"aem/pkg/process"
 	"aem/pkg/progress"
+	"aem/pkg/settings"
 	"io"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"path/filepath"
 )
 
 type Downloader struct {
-	logger *logger.Logger
-	client *http.Client
+	logger       *logger.Logger
+	client       *http.Client
+	cacheDir     string
+	cacheMaxSize int64
 }
 
 func New(logger *logger.Logger) *Downloader {
+	cfg, err := settings.Load()
+	if err != nil {
+		logger.Debug("Using default download settings: %v", err)
+	}
+
 	return &Downloader{
-		logger: logger,
-		client: &http.Client{},
+		logger:       logger,
+		client:       NewClient(cfg),
+		cacheDir:     cfg.String("cache.dir"),
+		cacheMaxSize: cfg.CacheMaxSize(),
+	}
+}
+
+// NewClient returns an HTTP client that honours the proxy setting, falling
+// back to the standard proxy environment variables.
+func NewClient(cfg *settings.Settings) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy := cfg.String("proxy"); proxy != "" {
+		if proxyURL, err := neturl.Parse(proxy); err == nil {
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
 	}
+	return &http.Client{Transport: transport}
 }
 
 func (d *Downloader) Download(url, destPath string) error {
+	if d.restoreFromCache(url, destPath) {
+		return nil
+	}
+
+	if err := d.download(url, destPath); err != nil {
+		return err
+	}
+
+	d.storeInCache(url, destPath)
+	return nil
+}
+
+func (d *Downloader) download(url, destPath string) error {
 	d.logger.Debug("Downloading from: %s", url)
 
 	req, err := http.NewRequestWithContext(process.Context(), http.MethodGet, url, nil)
@@ -67,10 +103,23 @@ func (d *Downloader) Download(url, destPath string) error {
 func (d *Downloader) GetHTML(url string) (io.ReadCloser, error) {
 	d.logger.Debug("Fetching HTML from: %s", url)
 
+	resp, err := d.Get(url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Get fetches url with header added to the request. Only a 200 response is
+// returned, and the caller closes its body.
+func (d *Downloader) Get(url string, header http.Header) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(process.Context(), http.MethodGet, url, nil)
 	if err != nil {
 		return nil, errors.NewDownloadError("failed to create HTTP request", err)
 	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
 
 	resp, err := d.client.Do(req)
 	if err != nil {
@@ -82,5 +131,5 @@ func (d *Downloader) GetHTML(url string) (io.ReadCloser, error) {
 		return nil, errors.NewDownloadError("HTTP request failed with status: "+resp.Status, nil)
 	}
 
-	return resp.Body, nil
+	return resp, nil
 }
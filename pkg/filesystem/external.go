@@ -0,0 +1,125 @@
+package filesystem
+
+import (
+	"aem/pkg/errors"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExternalFileName records, per module directory in sys_installed, the
+// entries that point at runtimes aem did not install.
+const ExternalFileName = ".external.json"
+
+// ExternalRuntime is a runtime linked into sys_installed from elsewhere. aem
+// selects it like any other version but never deletes what it points at.
+type ExternalRuntime struct {
+	Name    string `json:"name" yaml:"name"`
+	Path    string `json:"path" yaml:"path"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	Source  string `json:"source,omitempty" yaml:"source,omitempty"`
+}
+
+// ExternalRuntimes returns the external runtimes registered in moduleDir,
+// keyed by entry name.
+func (fs *FileSystem) ExternalRuntimes(moduleDir string) (map[string]ExternalRuntime, error) {
+	runtimes := make(map[string]ExternalRuntime)
+
+	data, err := os.ReadFile(filepath.Join(moduleDir, ExternalFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return runtimes, nil
+		}
+		return nil, errors.NewFileSystemError("failed to read external runtimes", err)
+	}
+	if err := json.Unmarshal(data, &runtimes); err != nil {
+		return nil, errors.NewFileSystemError("failed to parse "+filepath.Join(moduleDir, ExternalFileName), err)
+	}
+	return runtimes, nil
+}
+
+// AddExternal links runtime.Name in moduleDir to runtime.Path and records it
+// as external.
+func (fs *FileSystem) AddExternal(moduleDir string, runtime ExternalRuntime) error {
+	unlock, err := fs.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	runtimes, err := fs.ExternalRuntimes(moduleDir)
+	if err != nil {
+		return err
+	}
+	if err := fs.CreateSymlink(filepath.Join(moduleDir, runtime.Name), runtime.Path); err != nil {
+		return err
+	}
+
+	runtimes[runtime.Name] = runtime
+	return fs.writeExternal(moduleDir, runtimes)
+}
+
+// RemoveExternal unregisters name and removes its link, leaving the runtime it
+// points at untouched.
+func (fs *FileSystem) RemoveExternal(moduleDir, name string) error {
+	unlock, err := fs.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	runtimes, err := fs.ExternalRuntimes(moduleDir)
+	if err != nil {
+		return err
+	}
+	if _, ok := runtimes[name]; !ok {
+		return errors.NewValidationError(name + " is not an external runtime")
+	}
+
+	if err := os.Remove(filepath.Join(moduleDir, name)); err != nil && !os.IsNotExist(err) {
+		return errors.NewFileSystemError("failed to remove link "+filepath.Join(moduleDir, name), err)
+	}
+	delete(runtimes, name)
+	return fs.writeExternal(moduleDir, runtimes)
+}
+
+// ExternalName reports whether version names an external runtime in
+// moduleDir, with or without a leading "v", and returns the entry name.
+func (fs *FileSystem) ExternalName(moduleDir, version string) (string, bool) {
+	runtimes, err := fs.ExternalRuntimes(moduleDir)
+	if err != nil {
+		fs.logger.Debug("Ignoring external runtimes: %v", err)
+		return "", false
+	}
+	for _, name := range []string{version, strings.TrimPrefix(version, "v")} {
+		if _, ok := runtimes[name]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (fs *FileSystem) writeExternal(moduleDir string, runtimes map[string]ExternalRuntime) error {
+	path := filepath.Join(moduleDir, ExternalFileName)
+	if len(runtimes) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return errors.NewFileSystemError("failed to remove "+path, err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(runtimes, "", "  ")
+	if err != nil {
+		return errors.NewFileSystemError("failed to encode external runtimes", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return errors.NewFileSystemError("failed to write "+path, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return errors.NewFileSystemError("failed to write "+path, err)
+	}
+	return nil
+}
@@ -3,29 +3,20 @@ package filesystem
 import (
 	"aem/pkg/errors"
 	"aem/pkg/logger"
+	"aem/pkg/settings"
 	"aem/pkg/state"
-	"aem/pkg/version"
+	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
-	"strings"
 )
 
 type FileSystem struct {
-	logger     *logger.Logger
-	versionMgr *version.Manager
+	logger *logger.Logger
 }
 
 func New(logger *logger.Logger) *FileSystem {
-	aemHome := resolveAEMHome()
-	var configPath string
-	if aemHome != "" {
-		configPath = filepath.Join(aemHome, "versions.json")
-	}
-
 	return &FileSystem{
-		logger:     logger,
-		versionMgr: version.NewManager(logger, configPath),
+		logger: logger,
 	}
 }
 
@@ -61,15 +52,14 @@ func (fs *FileSystem) Move(src, dst string) error {
 func (fs *FileSystem) CreateSymlink(link, target string) error {
 	fs.logger.Debug("Creating symlink: %s -> %s", link, target)
 
-	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
-		return errors.NewFileSystemError("failed to create symlink parent directory", err)
+	unlock, err := fs.Lock()
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
-	// Remove existing symlink if it exists
-	if _, err := os.Lstat(link); err == nil {
-		if err := os.Remove(link); err != nil {
-			return errors.NewFileSystemError("failed to remove existing symlink", err)
-		}
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		return errors.NewFileSystemError("failed to create symlink parent directory", err)
 	}
 
 	// Get absolute path for target
@@ -78,49 +68,21 @@ func (fs *FileSystem) CreateSymlink(link, target string) error {
 		return errors.NewFileSystemError("failed to get absolute path", err)
 	}
 
-	// Create symlink
-	if err := os.Symlink(absTarget, link); err != nil {
-		if runtime.GOOS == "windows" {
-			return errors.NewFileSystemError("failed to create symlink (may need administrator privileges on Windows)", err)
-		}
-		return errors.NewFileSystemError("failed to create symlink", err)
+	// Create the new link next to the old one and swap it in, so the link
+	// keeps resolving while builds are running
+	tmpLink := fmt.Sprintf("%s.tmp-%d", link, os.Getpid())
+	_ = os.Remove(tmpLink)
+	if err := fs.createDirLink(tmpLink, absTarget); err != nil {
+		return err
+	}
+	if err := replaceLink(tmpLink, link); err != nil {
+		_ = os.Remove(tmpLink)
+		return errors.NewFileSystemError("failed to switch symlink", err)
 	}
 
 	return nil
 }
 
-func (fs *FileSystem) extractModuleVersion(target string) (string, string) {
-	// Normalize path separators
-	normalizedPath := filepath.ToSlash(target)
-	pathParts := strings.Split(normalizedPath, "/")
-
-	// Look for module and version in path
-	for i, part := range pathParts {
-		if (part == "node" || part == "java") && i+1 < len(pathParts) {
-			module := part
-			version := pathParts[i+1]
-			// Clean version string (remove 'v' prefix if present)
-			if strings.HasPrefix(version, "v") {
-				version = strings.TrimPrefix(version, "v")
-			}
-			return module, version
-		}
-	}
-	return "", ""
-}
-
-func (fs *FileSystem) updateVersionManager(module, version string) error {
-	switch module {
-	case "node":
-		return fs.versionMgr.SetNodeVersion(version)
-	case "java":
-		return fs.versionMgr.SetJavaVersion(version)
-	default:
-		fs.logger.Debug("Unknown module type: %s", module)
-		return nil
-	}
-}
-
 func (fs *FileSystem) ListDir(path string) ([]os.DirEntry, error) {
 	fs.logger.Debug("Listing directory: %s", path)
 	entries, err := os.ReadDir(path)
@@ -132,7 +94,7 @@ func (fs *FileSystem) ListDir(path string) ([]os.DirEntry, error) {
 
 // GetAEMHome returns the AEM_HOME directory, creating it if necessary
 func (fs *FileSystem) GetAEMHome() (string, error) {
-	aemHome := resolveAEMHome()
+	aemHome := settings.Home()
 	if aemHome == "" {
 		return "", errors.NewValidationError("unable to determine AEM home directory")
 	}
@@ -194,23 +156,5 @@ func (fs *FileSystem) GetState() (*state.State, error) {
 		return nil, err
 	}
 
-	return state.New(state.NewOSReader(), currentRoot), nil
-}
-
-// GetVersionManager returns the version manager instance
-func (fs *FileSystem) GetVersionManager() *version.Manager {
-	return fs.versionMgr
-}
-
-func resolveAEMHome() string {
-	if value := strings.TrimSpace(os.Getenv("AEM_HOME")); value != "" {
-		return value
-	}
-
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return ""
-	}
-
-	return filepath.Join(homeDir, ".aem")
+	return state.New(state.NewOSReader(), fs, currentRoot), nil
 }
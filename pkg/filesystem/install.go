@@ -0,0 +1,297 @@
+package filesystem
+
+import (
+	"aem/pkg/errors"
+	"aem/pkg/process"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+const (
+	// InstallMarker is written into an install once it is fully extracted.
+	InstallMarker = ".aem-complete"
+
+	stagingPrefix = "stage-"
+	backupSuffix  = ".aem-old"
+)
+
+// IncompleteInstall describes a leftover from an interrupted install.
+type IncompleteInstall struct {
+	Path   string
+	Reason string
+}
+
+// NewStagingDir creates a unique directory under AEM_HOME/tmp that a single
+// install downloads and extracts into before it is committed.
+func (fs *FileSystem) NewStagingDir(name string) (string, error) {
+	tmpDir, err := fs.GetTempDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := os.MkdirTemp(tmpDir, stagingPrefix+name+"-")
+	if err != nil {
+		return "", errors.NewFileSystemError("failed to create staging directory", err)
+	}
+	fs.logger.Debug("Staging install in: %s", dir)
+	return dir, nil
+}
+
+// CommitInstall marks staged as complete and renames it to finalPath. An
+// existing install at finalPath is moved aside first and restored if the
+// rename fails, so finalPath is never left half-populated.
+func (fs *FileSystem) CommitInstall(staged, finalPath string) error {
+	if err := process.Context().Err(); err != nil {
+		return errors.NewFileSystemError("install interrupted", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(staged, InstallMarker), nil, 0644); err != nil {
+		return errors.FileWriteSystemError("failed to write install marker", err)
+	}
+	if err := fs.EnsureDir(filepath.Dir(finalPath)); err != nil {
+		return err
+	}
+
+	backup := finalPath + backupSuffix
+	if _, err := os.Lstat(finalPath); err == nil {
+		if err := fs.RemoveAll(backup); err != nil {
+			return err
+		}
+		if err := fs.Move(finalPath, backup); err != nil {
+			return err
+		}
+	}
+
+	if err := fs.Move(staged, finalPath); err != nil {
+		if _, statErr := os.Lstat(backup); statErr == nil {
+			if restoreErr := os.Rename(backup, finalPath); restoreErr != nil {
+				fs.logger.Error("Failed to restore %s: %v", finalPath, restoreErr)
+			}
+		}
+		return err
+	}
+
+	if err := fs.RemoveAll(backup); err != nil {
+		fs.logger.Debug("Failed to remove previous install %s: %v", backup, err)
+	}
+	return nil
+}
+
+// InstallComplete reports whether path holds a finished install. Installs
+// made before completion markers existed, and runtimes linked in from
+// elsewhere, count as complete when probe, a path relative to the install
+// such as "bin/node", exists inside them. It never writes; installers call
+// MarkInstallComplete to backfill the marker.
+func (fs *FileSystem) InstallComplete(path, probe string) bool {
+	if fs.Exists(filepath.Join(path, InstallMarker)) {
+		return true
+	}
+	return fs.Exists(path) && probe != "" && fs.Exists(filepath.Join(path, probe))
+}
+
+// MarkInstallComplete writes the completion marker into an install made
+// before markers existed, once InstallComplete accepted it. Runtimes linked in
+// from elsewhere are not ours to mark and are left alone. Call it with the
+// lock held.
+func (fs *FileSystem) MarkInstallComplete(path string) {
+	marker := filepath.Join(path, InstallMarker)
+	if fs.Exists(marker) {
+		return
+	}
+	if info, err := os.Lstat(path); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		return
+	}
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		fs.logger.Debug("Failed to mark %s as complete: %v", path, err)
+	}
+}
+
+// IsInstallBackup reports whether name is an install CommitInstall moved
+// aside, rather than an installed version.
+func IsInstallBackup(name string) bool {
+	return strings.HasSuffix(name, backupSuffix)
+}
+
+// InstalledVersions returns the names of the installs in moduleDir: its
+// directories, and the links to directories that 'aem link' and 'aem import'
+// register. Hidden entries and installs moved aside by CommitInstall are
+// skipped. A missing moduleDir has no installs.
+func (fs *FileSystem) InstalledVersions(moduleDir string) ([]string, error) {
+	entries, err := os.ReadDir(moduleDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.NewFileSystemError("failed to read directory", err)
+	}
+
+	var versions []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || IsInstallBackup(name) {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(moduleDir, name)); err == nil && info.IsDir() {
+			versions = append(versions, name)
+		}
+	}
+	return versions, nil
+}
+
+// FindIncompleteInstalls returns staging directories left in AEM_HOME/tmp,
+// installs that were moved aside but never restored, and install
+// directories in each root that are neither marked nor contain its probe.
+func (fs *FileSystem) FindIncompleteInstalls(roots map[string]string) ([]IncompleteInstall, error) {
+	var found []IncompleteInstall
+
+	tmpDir, err := fs.GetTempDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fs.ListDir(tmpDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), stagingPrefix) {
+			found = append(found, IncompleteInstall{
+				Path:   filepath.Join(tmpDir, entry.Name()),
+				Reason: "staging directory from an interrupted install",
+			})
+		}
+	}
+
+	for root, probe := range roots {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, errors.NewFileSystemError("failed to read directory", err)
+		}
+
+		for _, entry := range entries {
+			path := filepath.Join(root, entry.Name())
+			switch {
+			case IsInstallBackup(entry.Name()):
+				found = append(found, IncompleteInstall{Path: path, Reason: "previous install left behind by an interrupted switch"})
+			case entry.IsDir() && !fs.InstallComplete(path, probe):
+				found = append(found, IncompleteInstall{Path: path, Reason: "install is missing its completion marker"})
+			}
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Path < found[j].Path
+	})
+	return found, nil
+}
+
+// RepairIncompleteInstall restores a moved-aside install when nothing took
+// its place, and removes the leftover otherwise.
+func (fs *FileSystem) RepairIncompleteInstall(install IncompleteInstall) error {
+	if strings.HasSuffix(install.Path, backupSuffix) {
+		original := strings.TrimSuffix(install.Path, backupSuffix)
+		if !fs.Exists(original) {
+			return fs.Move(install.Path, original)
+		}
+	}
+	return fs.RemoveAll(install.Path)
+}
+
+// LatestInstalled returns the newest install in moduleDir that matches
+// version exactly or as a prefix such as "20" or "20.11", or "" if none does.
+// Runtimes registered with 'aem link' or 'aem import' count as installs.
+func (fs *FileSystem) LatestInstalled(moduleDir, version string) string {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	installed, err := fs.InstalledVersions(moduleDir)
+	if err != nil || version == "" {
+		return ""
+	}
+
+	var matched []string
+	for _, installVersion := range installed {
+		name := strings.TrimPrefix(installVersion, "v")
+		if name == version || strings.HasPrefix(name, version+".") {
+			matched = append(matched, installVersion)
+		}
+	}
+	if len(matched) == 0 {
+		return ""
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return semver.Compare("v"+strings.TrimPrefix(matched[i], "v"), "v"+strings.TrimPrefix(matched[j], "v")) < 0
+	})
+	return filepath.Join(moduleDir, matched[len(matched)-1])
+}
+
+// CopyDir copies the tree at src to dst, preserving file modes and symlinks.
+func (fs *FileSystem) CopyDir(src, dst string) error {
+	fs.logger.Debug("Copying %s to %s", src, dst)
+
+	// Resolve src itself so a linked install is copied, not re-linked
+	root, err := filepath.EvalSymlinks(src)
+	if err != nil {
+		return errors.NewFileSystemError("failed to resolve "+src, err)
+	}
+
+	err = filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctxErr := process.Context().Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		default:
+			return copyFile(path, target, info.Mode().Perm())
+		}
+	})
+	if err != nil {
+		return errors.NewFileSystemError("failed to copy "+src, err)
+	}
+	return nil
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
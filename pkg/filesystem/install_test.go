@@ -0,0 +1,130 @@
+package filesystem
+
+import (
+	"aem/pkg/logger"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLatestInstalled(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("AEM_HOME", home)
+	fs := New(logger.New(false))
+
+	moduleDir := filepath.Join(home, "sys_installed", "node")
+	for _, dir := range []string{"v18.20.4", "v20.9.0"} {
+		if err := os.MkdirAll(filepath.Join(moduleDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A runtime from 'aem link' is a link to a directory outside AEM_HOME
+	external := filepath.Join(t.TempDir(), "node-20.11.1")
+	if err := os.MkdirAll(external, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.CreateSymlink(filepath.Join(moduleDir, "v20.11.1"), external); err != nil {
+		t.Fatal(err)
+	}
+	// A dangling link and a plain file are not installs
+	if err := os.Symlink(filepath.Join(home, "missing"), filepath.Join(moduleDir, "v20.12.0")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(moduleDir, "v20.13.0"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{version: "20", want: "v20.11.1"},
+		{version: "v20.11.1", want: "v20.11.1"},
+		{version: "20.9", want: "v20.9.0"},
+		{version: "18", want: "v18.20.4"},
+		{version: "20.12", want: ""},
+		{version: "22", want: ""},
+		{version: "", want: ""},
+	}
+	for _, tt := range tests {
+		want := ""
+		if tt.want != "" {
+			want = filepath.Join(moduleDir, tt.want)
+		}
+		if got := fs.LatestInstalled(moduleDir, tt.version); got != want {
+			t.Errorf("LatestInstalled(%q) = %q, want %q", tt.version, got, want)
+		}
+	}
+}
+
+func TestInstalledVersions(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("AEM_HOME", home)
+	fs := New(logger.New(false))
+
+	moduleDir := filepath.Join(home, "sys_installed", "java")
+	for _, dir := range []string{"v17.0.12", "v21.0.4", "v21.0.4" + backupSuffix, ".hidden"} {
+		if err := os.MkdirAll(filepath.Join(moduleDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fs.CreateSymlink(filepath.Join(moduleDir, "system"), t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := fs.InstalledVersions(moduleDir)
+	if err != nil {
+		t.Fatalf("InstalledVersions() error = %v", err)
+	}
+	want := []string{"system", "v17.0.12", "v21.0.4"}
+	if len(versions) != len(want) {
+		t.Fatalf("InstalledVersions() = %v, want %v", versions, want)
+	}
+	for i := range want {
+		if versions[i] != want[i] {
+			t.Errorf("InstalledVersions()[%d] = %q, want %q", i, versions[i], want[i])
+		}
+	}
+
+	if versions, err := fs.InstalledVersions(filepath.Join(home, "missing")); err != nil || len(versions) != 0 {
+		t.Errorf("InstalledVersions() of a missing directory = %v, %v", versions, err)
+	}
+}
+
+func TestInstallCompleteDoesNotWrite(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("AEM_HOME", home)
+	fs := New(logger.New(false))
+
+	// An install made before completion markers existed
+	legacy := filepath.Join(home, "sys_installed", "node", "v18.20.4")
+	if err := os.MkdirAll(filepath.Join(legacy, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(legacy, "bin", "node"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	linked := filepath.Join(home, "sys_installed", "node", "system")
+	if err := fs.CreateSymlink(linked, legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	if !fs.InstallComplete(legacy, filepath.Join("bin", "node")) {
+		t.Fatal("InstallComplete() of an install with its probe = false")
+	}
+	if fs.InstallComplete(legacy, filepath.Join("bin", "java")) {
+		t.Error("InstallComplete() of an install without its probe = true")
+	}
+	if fs.Exists(filepath.Join(legacy, InstallMarker)) {
+		t.Fatal("InstallComplete() wrote the completion marker")
+	}
+
+	fs.MarkInstallComplete(linked)
+	if fs.Exists(filepath.Join(legacy, InstallMarker)) {
+		t.Fatal("MarkInstallComplete() marked a linked runtime")
+	}
+	fs.MarkInstallComplete(legacy)
+	if !fs.Exists(filepath.Join(legacy, InstallMarker)) {
+		t.Error("MarkInstallComplete() did not write the completion marker")
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package filesystem
+
+import (
+	"aem/pkg/errors"
+	"os"
+)
+
+func (fs *FileSystem) createDirLink(link, target string) error {
+	if err := os.Symlink(target, link); err != nil {
+		return errors.NewFileSystemError("failed to create symlink", err)
+	}
+	return nil
+}
+
+// replaceLink atomically renames tmpLink over link.
+func replaceLink(tmpLink, link string) error {
+	return os.Rename(tmpLink, link)
+}
@@ -0,0 +1,40 @@
+//go:build windows
+
+package filesystem
+
+import (
+	"aem/pkg/errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// createDirLink creates a symlink, falling back to a directory junction when
+// the user lacks the symlink privilege (no Developer Mode or elevation).
+// Junctions need no privilege and behave the same for directories.
+func (fs *FileSystem) createDirLink(link, target string) error {
+	symlinkErr := os.Symlink(target, link)
+	if symlinkErr == nil {
+		return nil
+	}
+
+	fs.logger.Debug("Symlink failed (%v), creating a junction instead", symlinkErr)
+	output, err := exec.Command("cmd", "/c", "mklink", "/J", link, target).CombinedOutput()
+	if err != nil {
+		return errors.NewFileSystemError("failed to create symlink or junction: "+strings.TrimSpace(string(output)), symlinkErr)
+	}
+	return nil
+}
+
+// replaceLink swaps tmpLink in for link. Windows cannot rename a directory
+// link over an existing one, so the old link is removed just before the
+// rename, which keeps the window without a link as short as possible.
+func replaceLink(tmpLink, link string) error {
+	if err := os.Rename(tmpLink, link); err == nil {
+		return nil
+	}
+	if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Rename(tmpLink, link)
+}
@@ -0,0 +1,154 @@
+package filesystem
+
+import (
+	"aem/pkg/errors"
+	"aem/pkg/process"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	lockFileName     = "aem.lock"
+	lockPollInterval = 250 * time.Millisecond
+)
+
+// The lock is reentrant within a process, so that setup can install several
+// runtimes concurrently while other aem processes wait for it to finish.
+var (
+	lockMu      sync.Mutex
+	lockHolders int
+	lockHandle  *os.File
+)
+
+// Lock takes an exclusive lock on AEM_HOME that is shared with every other
+// aem process, waiting for it to be released if necessary. Call the returned
+// function to release it.
+func (fs *FileSystem) Lock() (func(), error) {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	if lockHolders == 0 {
+		file, err := fs.acquireLock(true)
+		if err != nil {
+			return nil, err
+		}
+		lockHandle = file
+	}
+	return fs.holdLock(), nil
+}
+
+// TryLock takes the lock like Lock when no other aem process holds it. When
+// one does, it returns at once with a nil function and a description of the
+// holder, such as "PID 4242".
+func (fs *FileSystem) TryLock() (func(), string, error) {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	if lockHolders == 0 {
+		file, err := fs.acquireLock(false)
+		if err != nil {
+			return nil, "", err
+		}
+		if file == nil {
+			aemHome, err := fs.GetAEMHome()
+			if err != nil {
+				return nil, "", err
+			}
+			return nil, lockOwner(filepath.Join(aemHome, lockFileName)), nil
+		}
+		lockHandle = file
+	}
+	return fs.holdLock(), "", nil
+}
+
+// holdLock counts another holder of the lock; lockMu must be held.
+func (fs *FileSystem) holdLock() func() {
+	lockHolders++
+
+	var once sync.Once
+	return func() {
+		once.Do(fs.releaseLock)
+	}
+}
+
+// acquireLock locks the lock file, waiting for other processes to release it
+// if wait is set. Otherwise it returns a nil file when the lock is taken.
+func (fs *FileSystem) acquireLock(wait bool) (*os.File, error) {
+	aemHome, err := fs.GetAEMHome()
+	if err != nil {
+		return nil, err
+	}
+
+	lockPath := filepath.Join(aemHome, lockFileName)
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.NewFileSystemError("failed to open lock file", err)
+	}
+
+	waiting := false
+	for {
+		locked, err := tryLockFile(file)
+		if err != nil {
+			file.Close()
+			return nil, errors.NewFileSystemError("failed to lock "+lockPath, err)
+		}
+		if locked {
+			break
+		}
+		if !wait {
+			file.Close()
+			return nil, nil
+		}
+
+		if !waiting {
+			waiting = true
+			fs.logger.Info("Waiting for lock held by %s (%s)", lockOwner(lockPath), lockPath)
+		}
+
+		select {
+		case <-process.Context().Done():
+			file.Close()
+			return nil, errors.NewFileSystemError("interrupted while waiting for lock", process.Context().Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	if err := file.Truncate(0); err == nil {
+		_, _ = file.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	}
+	fs.logger.Debug("Acquired lock: %s", lockPath)
+	return file, nil
+}
+
+func (fs *FileSystem) releaseLock() {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	lockHolders--
+	if lockHolders > 0 || lockHandle == nil {
+		return
+	}
+
+	if err := unlockFile(lockHandle); err != nil {
+		fs.logger.Debug("Failed to release lock: %v", err)
+	}
+	lockHandle.Close()
+	lockHandle = nil
+}
+
+func lockOwner(lockPath string) string {
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return "another aem process"
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return "another aem process"
+	}
+	return fmt.Sprintf("PID %d", pid)
+}
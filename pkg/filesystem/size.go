@@ -0,0 +1,39 @@
+package filesystem
+
+import (
+	"aem/pkg/errors"
+	"aem/pkg/process"
+	"os"
+	"path/filepath"
+)
+
+// DirSize returns the total size of the files under path. Symlinks are
+// counted as links and not followed, so runtimes linked from elsewhere do not
+// add to the total.
+func (fs *FileSystem) DirSize(path string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(path, func(_ string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if ctxErr := process.Context().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return total, errors.NewFileSystemError("failed to measure "+path, err)
+	}
+	return total, nil
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"io"
 	"log"
 	"os"
 )
@@ -17,6 +18,12 @@ func New(debug bool) *Logger {
 	}
 }
 
+// SetOutput redirects log messages, e.g. to stderr when stdout carries
+// machine-readable output.
+func (l *Logger) SetOutput(w io.Writer) {
+	l.logger.SetOutput(w)
+}
+
 func (l *Logger) Debug(format string, args ...interface{}) {
 	if l.debug {
 		l.logger.Printf("[DEBUG] "+format, args...)
@@ -38,10 +38,20 @@ type manager struct {
 	order         []string
 	slots         map[string]*slotState
 	renderedLines int
+	out           io.Writer
 }
 
 var defaultManager = &manager{
 	slots: make(map[string]*slotState),
+	out:   os.Stdout,
+}
+
+// SetOutput redirects progress bars, e.g. to stderr when stdout carries
+// machine-readable output.
+func SetOutput(w io.Writer) {
+	defaultManager.mu.Lock()
+	defer defaultManager.mu.Unlock()
+	defaultManager.out = w
 }
 
 func New(label string, total int64) *Tracker {
@@ -141,7 +151,7 @@ func (m *manager) renderLocked() {
 	}
 
 	if m.renderedLines > 0 {
-		fmt.Fprintf(os.Stdout, "\033[%dA", m.renderedLines)
+		fmt.Fprintf(m.out, "\033[%dA", m.renderedLines)
 	}
 
 	for _, slot := range m.order {
@@ -149,7 +159,7 @@ func (m *manager) renderLocked() {
 		if slotState == nil {
 			continue
 		}
-		fmt.Fprintf(os.Stdout, "\r%s\033[K\n", renderSlot(slotState))
+		fmt.Fprintf(m.out, "\r%s\033[K\n", renderSlot(slotState))
 	}
 
 	m.renderedLines = len(m.order)
@@ -178,7 +188,7 @@ func renderStageOrPending(stages map[string]stageState, key string) string {
 
 func renderStage(stage stageState) string {
 	if stage.total <= 0 {
-		return humanizeBytes(stage.current)
+		return HumanizeBytes(stage.current)
 	}
 
 	percent := int64(0)
@@ -193,7 +203,7 @@ func renderStage(stage stageState) string {
 	}
 	bar := strings.Repeat("#", filled) + strings.Repeat("-", barWidth-filled)
 
-	return fmt.Sprintf("[%s] %3d%% (%s/%s)", bar, percent, humanizeBytes(stage.current), humanizeBytes(stage.total))
+	return fmt.Sprintf("[%s] %3d%% (%s/%s)", bar, percent, HumanizeBytes(stage.current), HumanizeBytes(stage.total))
 }
 
 type Writer struct {
@@ -246,7 +256,8 @@ func inferSlotAndStage(label string) (string, string) {
 	return slot, stage
 }
 
-func humanizeBytes(n int64) string {
+// HumanizeBytes formats n with a binary unit, e.g. "1.5 GB".
+func HumanizeBytes(n int64) string {
 	const unit = 1024
 	if n < unit {
 		return fmt.Sprintf("%d B", n)
@@ -0,0 +1,470 @@
+package settings
+
+import (
+	"aem/pkg/errors"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const FileName = "config.json"
+
+const (
+	SourceEnv     = "env"
+	SourceFile    = "file"
+	SourceDefault = "default"
+)
+
+// File is the on-disk layout of AEM_HOME/config.json.
+type File struct {
+	Symlinks    RuntimeValues `json:"symlinks,omitempty"`
+	Mirrors     RuntimeValues `json:"mirrors,omitempty"`
+	Defaults    RuntimeValues `json:"defaults,omitempty"`
+	Proxy       string        `json:"proxy,omitempty"`
+	Profile     string        `json:"profile,omitempty"`
+	Parallelism int           `json:"parallelism,omitempty"`
+	Cache       CacheValues   `json:"cache,omitempty"`
+	Shims       string        `json:"shims,omitempty"`
+	Node        NodeValues    `json:"node,omitempty"`
+}
+
+type RuntimeValues struct {
+	Node    string `json:"node,omitempty"`
+	Java    string `json:"java,omitempty"`
+	Gradle  string `json:"gradle,omitempty"`
+	Ruby    string `json:"ruby,omitempty"`
+	Python  string `json:"python,omitempty"`
+	Android string `json:"android,omitempty"`
+}
+
+type NodeValues struct {
+	GlobalPackages string `json:"global-packages,omitempty"`
+}
+
+type CacheValues struct {
+	Dir     string `json:"dir,omitempty"`
+	MaxSize string `json:"max-size,omitempty"`
+}
+
+// Value is a resolved setting together with where it came from.
+type Value struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+type definition struct {
+	key         string
+	env         string
+	description string
+	get         func(f *File) string
+	set         func(f *File, value string) error
+	fallback    func(s *Settings) string
+}
+
+func stringField(field func(f *File) *string) (func(f *File) string, func(f *File, value string) error) {
+	return func(f *File) string {
+			return *field(f)
+		}, func(f *File, value string) error {
+			*field(f) = value
+			return nil
+		}
+}
+
+func runtimeDefinitions(modules []string, prefix, envSuffix, description string, values func(f *File) *RuntimeValues, fallback func(s *Settings, module string) string) []definition {
+	var defs []definition
+	for _, module := range modules {
+		module := module
+		field := func(f *File) *string {
+			v := values(f)
+			switch module {
+			case "node":
+				return &v.Node
+			case "java":
+				return &v.Java
+			case "gradle":
+				return &v.Gradle
+			case "ruby":
+				return &v.Ruby
+			case "python":
+				return &v.Python
+			default:
+				return &v.Android
+			}
+		}
+		get, set := stringField(field)
+		defs = append(defs, definition{
+			key:         prefix + "." + module,
+			env:         "AEM_" + strings.ToUpper(module) + "_" + envSuffix,
+			description: fmt.Sprintf(description, module),
+			get:         get,
+			set:         set,
+			fallback: func(s *Settings) string {
+				return fallback(s, module)
+			},
+		})
+	}
+	return defs
+}
+
+var defaultMirrors = map[string]string{
+	"node":    "https://nodejs.org/dist",
+	"java":    "https://api.azul.com/metadata/v1/zulu/packages",
+	"gradle":  "https://services.gradle.org",
+	"ruby":    "",
+	"python":  "https://api.github.com/repos/astral-sh/python-build-standalone",
+	"android": "https://dl.google.com/android/repository",
+}
+
+var definitions = func() []definition {
+	var defs []definition
+	allModules := []string{"node", "java", "gradle", "ruby", "python", "android"}
+	defs = append(defs, runtimeDefinitions(allModules, "symlinks", "SYMLINK", "active %s link", func(f *File) *RuntimeValues {
+		return &f.Symlinks
+	}, func(s *Settings, module string) string {
+		return filepath.Join(s.home, "current", module)
+	})...)
+	defs = append(defs, runtimeDefinitions(allModules, "mirrors", "MIRROR", "base URL %s downloads and metadata are fetched from", func(f *File) *RuntimeValues {
+		return &f.Mirrors
+	}, func(s *Settings, module string) string {
+		return defaultMirrors[module]
+	})...)
+	defs = append(defs, runtimeDefinitions([]string{"node", "java", "gradle", "ruby", "python"}, "defaults", "DEFAULT", "%s version used outside of any project", func(f *File) *RuntimeValues {
+		return &f.Defaults
+	}, func(s *Settings, module string) string {
+		return ""
+	})...)
+
+	proxyGet, proxySet := stringField(func(f *File) *string { return &f.Proxy })
+	profileGet, profileSet := stringField(func(f *File) *string { return &f.Profile })
+	cacheDirGet, cacheDirSet := stringField(func(f *File) *string { return &f.Cache.Dir })
+	globalPackagesGet, globalPackagesSet := stringField(func(f *File) *string { return &f.Node.GlobalPackages })
+
+	defs = append(defs,
+		definition{
+			key:         "proxy",
+			env:         "AEM_PROXY",
+			description: "HTTP(S) proxy URL for downloads; HTTPS_PROXY is used when unset",
+			get:         proxyGet,
+			set:         proxySet,
+			fallback:    func(s *Settings) string { return "" },
+		},
+		definition{
+			key:         "profile",
+			env:         "AEM_PROFILE",
+			description: "project profile applied when --profile is not given",
+			get:         profileGet,
+			set:         profileSet,
+			fallback:    func(s *Settings) string { return "" },
+		},
+		definition{
+			key:         "parallelism",
+			env:         "AEM_PARALLELISM",
+			description: "maximum number of runtimes installed at the same time",
+			get: func(f *File) string {
+				if f.Parallelism == 0 {
+					return ""
+				}
+				return strconv.Itoa(f.Parallelism)
+			},
+			set: func(f *File, value string) error {
+				if value == "" {
+					f.Parallelism = 0
+					return nil
+				}
+				n, err := parsePositiveInt(value)
+				if err != nil {
+					return err
+				}
+				f.Parallelism = n
+				return nil
+			},
+			fallback: func(s *Settings) string { return "2" },
+		},
+		definition{
+			key:         "shims",
+			env:         "AEM_SHIMS",
+			description: "regenerate the shims in AEM_HOME/shims after every install (true or false)",
+			get:         func(f *File) string { return f.Shims },
+			set: func(f *File, value string) error {
+				if value != "" {
+					if _, err := strconv.ParseBool(value); err != nil {
+						return errors.NewValidationError(fmt.Sprintf("expected true or false, got %q", value))
+					}
+				}
+				f.Shims = value
+				return nil
+			},
+			fallback: func(s *Settings) string { return "false" },
+		},
+		definition{
+			key:         "cache.dir",
+			env:         "AEM_CACHE_DIR",
+			description: "directory downloaded archives are cached in",
+			get:         cacheDirGet,
+			set:         cacheDirSet,
+			fallback:    func(s *Settings) string { return filepath.Join(s.home, "cache") },
+		},
+		definition{
+			key:         "cache.max-size",
+			env:         "AEM_CACHE_MAX_SIZE",
+			description: "size limit for the download cache such as 5GB; 0 disables caching",
+			get:         func(f *File) string { return f.Cache.MaxSize },
+			set: func(f *File, value string) error {
+				if value != "" {
+					if _, err := ParseSize(value); err != nil {
+						return err
+					}
+				}
+				f.Cache.MaxSize = value
+				return nil
+			},
+			fallback: func(s *Settings) string { return "0" },
+		},
+		definition{
+			key:         "node.global-packages",
+			env:         "AEM_NODE_GLOBAL_PACKAGES",
+			description: "comma-separated npm packages installed globally into every Node install",
+			get:         globalPackagesGet,
+			set:         globalPackagesSet,
+			fallback:    func(s *Settings) string { return "" },
+		},
+	)
+	return defs
+}()
+
+// Settings resolves global settings with the precedence env > file > default.
+type Settings struct {
+	home string
+	path string
+	file File
+}
+
+// Home returns AEM_HOME, which can only come from the environment or the
+// default location since the config file itself lives inside it.
+func Home() string {
+	if value := strings.TrimSpace(os.Getenv("AEM_HOME")); value != "" {
+		return value
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(homeDir, ".aem")
+}
+
+// Load reads AEM_HOME/config.json. On error the returned Settings still
+// resolves env and default values, so callers that cannot fail may use it.
+func Load() (*Settings, error) {
+	s := &Settings{home: Home()}
+	if s.home == "" {
+		return s, errors.NewValidationError("unable to determine AEM home directory")
+	}
+	s.path = filepath.Join(s.home, FileName)
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return s, errors.NewFileSystemError("failed to read "+s.path, err)
+	}
+
+	if err := json.Unmarshal(data, &s.file); err != nil {
+		s.file = File{}
+		return s, errors.NewFileSystemError("failed to parse "+s.path, err)
+	}
+
+	return s, nil
+}
+
+func (s *Settings) Path() string {
+	return s.path
+}
+
+func (s *Settings) Get(key string) (Value, error) {
+	def, ok := lookup(key)
+	if !ok {
+		return Value{}, unknownKeyError(key)
+	}
+	return s.resolve(def), nil
+}
+
+// String returns the resolved value of a known key, or "" for unknown keys.
+func (s *Settings) String(key string) string {
+	value, _ := s.Get(key)
+	return value.Value
+}
+
+func (s *Settings) List() []Value {
+	values := make([]Value, 0, len(definitions))
+	for _, def := range definitions {
+		values = append(values, s.resolve(def))
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Key < values[j].Key
+	})
+	return values
+}
+
+// Set stores value in the config file; call Save to persist it.
+func (s *Settings) Set(key, value string) error {
+	def, ok := lookup(key)
+	if !ok {
+		return unknownKeyError(key)
+	}
+	return def.set(&s.file, strings.TrimSpace(value))
+}
+
+func (s *Settings) Unset(key string) error {
+	return s.Set(key, "")
+}
+
+func (s *Settings) Save() error {
+	if s.path == "" {
+		return errors.NewValidationError("unable to determine AEM home directory")
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return errors.NewFileSystemError("failed to create AEM home directory", err)
+	}
+
+	data, err := json.MarshalIndent(s.file, "", "  ")
+	if err != nil {
+		return errors.NewFileSystemError("failed to encode "+s.path, err)
+	}
+	if err := os.WriteFile(s.path, append(data, '\n'), 0644); err != nil {
+		return errors.FileWriteSystemError("failed to write "+s.path, err)
+	}
+	return nil
+}
+
+// SymlinkPath returns where the active link for module lives.
+func (s *Settings) SymlinkPath(module string) string {
+	return s.String("symlinks." + module)
+}
+
+// DefaultVersion returns the user-level default version for module, if any.
+func (s *Settings) DefaultVersion(module string) string {
+	return s.String("defaults." + module)
+}
+
+// Mirror returns the base URL for module without a trailing slash.
+func (s *Settings) Mirror(module string) string {
+	return strings.TrimSuffix(s.String("mirrors."+module), "/")
+}
+
+func (s *Settings) Parallelism() int {
+	n, err := parsePositiveInt(s.String("parallelism"))
+	if err != nil {
+		return 1
+	}
+	return n
+}
+
+// ShimsEnabled reports whether shim mode is on.
+func (s *Settings) ShimsEnabled() bool {
+	enabled, _ := strconv.ParseBool(s.String("shims"))
+	return enabled
+}
+
+// CacheMaxSize returns the download cache limit in bytes; 0 means disabled.
+func (s *Settings) CacheMaxSize() int64 {
+	size, err := ParseSize(s.String("cache.max-size"))
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// GlobalPackages returns the npm packages installed globally into every Node
+// install.
+func (s *Settings) GlobalPackages() []string {
+	return strings.FieldsFunc(s.String("node.global-packages"), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+// Describe returns the environment variable and description of a key.
+func Describe(key string) (env, description string, ok bool) {
+	def, found := lookup(key)
+	if !found {
+		return "", "", false
+	}
+	return def.env, def.description, true
+}
+
+func Keys() []string {
+	keys := make([]string, 0, len(definitions))
+	for _, def := range definitions {
+		keys = append(keys, def.key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Settings) resolve(def definition) Value {
+	if value := strings.TrimSpace(os.Getenv(def.env)); value != "" {
+		return Value{Key: def.key, Value: value, Source: SourceEnv}
+	}
+	if value := def.get(&s.file); value != "" {
+		return Value{Key: def.key, Value: value, Source: SourceFile}
+	}
+	return Value{Key: def.key, Value: def.fallback(s), Source: SourceDefault}
+}
+
+func lookup(key string) (definition, bool) {
+	for _, def := range definitions {
+		if def.key == key {
+			return def, true
+		}
+	}
+	return definition{}, false
+}
+
+func unknownKeyError(key string) error {
+	return errors.NewValidationError(fmt.Sprintf("unknown setting %q (known settings: %s)", key, strings.Join(Keys(), ", ")))
+}
+
+func parsePositiveInt(value string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 1 {
+		return 0, errors.NewValidationError(fmt.Sprintf("expected a positive integer, got %q", value))
+	}
+	return n, nil
+}
+
+// ParseSize parses sizes such as "512MB", "5GB" or a plain byte count.
+func ParseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	units := []struct {
+		suffix string
+		factor int64
+	}{
+		{"TB", 1 << 40},
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	}
+
+	factor := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			factor = unit.factor
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, errors.NewValidationError(fmt.Sprintf("invalid size %q (expected a value such as 512MB or 5GB)", value))
+	}
+	return int64(n * float64(factor)), nil
+}
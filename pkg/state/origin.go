@@ -0,0 +1,85 @@
+package state
+
+import (
+	"aem/pkg/errors"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const originsFileName = ".origins.json"
+
+const (
+	OriginProject = "project"
+	OriginDefault = "default"
+	OriginManual  = "manual"
+)
+
+// Origin records why a runtime link points at its current version.
+type Origin struct {
+	Source  string `json:"source"`
+	Version string `json:"version"`
+	Config  string `json:"config,omitempty"`
+}
+
+// Origin returns the recorded origin of the active module, or a zero Origin
+// when nothing was recorded or the link has since been switched elsewhere.
+func (s *State) Origin(module string) (Origin, error) {
+	origins, err := s.readOrigins()
+	if err != nil {
+		return Origin{}, err
+	}
+
+	origin, ok := origins[module]
+	if !ok {
+		return Origin{}, nil
+	}
+
+	current, err := s.currentVersion(module)
+	if err != nil {
+		return Origin{}, err
+	}
+	if current == "" || current != strings.TrimPrefix(origin.Version, "v") {
+		return Origin{}, nil
+	}
+
+	return origin, nil
+}
+
+func (s *State) SetOrigin(module string, origin Origin) error {
+	return s.update(func() error {
+		origins, err := s.readOrigins()
+		if err != nil {
+			return err
+		}
+		origins[module] = origin
+
+		data, err := json.MarshalIndent(origins, "", "  ")
+		if err != nil {
+			return errors.NewFileSystemError("failed to encode runtime origins", err)
+		}
+		return s.writeFile(s.originsPath(), data, "runtime origins")
+	})
+}
+
+func (s *State) readOrigins() (map[string]Origin, error) {
+	origins := make(map[string]Origin)
+
+	data, err := os.ReadFile(s.originsPath())
+	if err != nil {
+		if isNotExist(err) {
+			return origins, nil
+		}
+		return nil, errors.NewFileSystemError("failed to read runtime origins", err)
+	}
+
+	if err := json.Unmarshal(data, &origins); err != nil {
+		return nil, errors.NewFileSystemError("failed to parse runtime origins", err)
+	}
+	return origins, nil
+}
+
+func (s *State) originsPath() string {
+	return filepath.Join(s.currentRoot, originsFileName)
+}
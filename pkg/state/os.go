@@ -1,6 +1,7 @@
 package state
 
 import (
+	"aem/pkg/errors"
 	"os"
 )
 
@@ -17,3 +18,34 @@ func (r *OSReader) Readlink(name string) (string, error) {
 func isNotExist(err error) bool {
 	return os.IsNotExist(err)
 }
+
+// update runs the read-modify-write fn under the lock, when there is one.
+func (s *State) update(fn func() error) error {
+	if s.locker == nil {
+		return fn()
+	}
+	unlock, err := s.locker.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fn()
+}
+
+// writeFile writes next to path and renames, so concurrent readers never see
+// a partially written file.
+func (s *State) writeFile(path string, data []byte, what string) error {
+	if err := os.MkdirAll(s.currentRoot, 0755); err != nil {
+		return errors.NewFileSystemError("failed to create current directory", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return errors.FileWriteSystemError("failed to write "+what, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return errors.FileWriteSystemError("failed to write "+what, err)
+	}
+	return nil
+}
@@ -10,14 +10,21 @@ type LinkReader interface {
 	Readlink(name string) (string, error)
 }
 
+// Locker serializes updates of the state files with other aem processes.
+type Locker interface {
+	Lock() (func(), error)
+}
+
 type State struct {
 	reader      LinkReader
+	locker      Locker
 	currentRoot string
 }
 
-func New(reader LinkReader, currentRoot string) *State {
+func New(reader LinkReader, locker Locker, currentRoot string) *State {
 	return &State{
 		reader:      reader,
+		locker:      locker,
 		currentRoot: currentRoot,
 	}
 }
@@ -30,6 +37,24 @@ func (s *State) CurrentJavaVersion() (string, error) {
 	return s.currentVersion("java")
 }
 
+func (s *State) CurrentGradleVersion() (string, error) {
+	return s.currentVersion("gradle")
+}
+
+func (s *State) CurrentRubyVersion() (string, error) {
+	return s.currentVersion("ruby")
+}
+
+func (s *State) CurrentPythonVersion() (string, error) {
+	return s.currentVersion("python")
+}
+
+// CurrentVersion returns the active version of a versioned module such as
+// node or java, without a leading "v".
+func (s *State) CurrentVersion(module string) (string, error) {
+	return s.currentVersion(module)
+}
+
 func (s *State) CurrentAndroidPath() (string, error) {
 	linkPath := filepath.Join(s.currentRoot, "android")
 	target, err := s.reader.Readlink(linkPath)
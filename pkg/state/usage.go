@@ -0,0 +1,121 @@
+package state
+
+import (
+	"aem/pkg/errors"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const usageFileName = ".usage.json"
+
+// Usage records which projects reference which installs and when each
+// install was last selected, so unused versions can be garbage collected.
+type Usage struct {
+	// Projects is keyed by the path of the project config.
+	Projects map[string]ProjectUsage `json:"projects"`
+	// Installs is keyed by UsageKey.
+	Installs map[string]time.Time `json:"installs"`
+}
+
+type ProjectUsage struct {
+	// Runtimes maps each module to the version the project resolved to on
+	// its last setup, without a leading "v".
+	Runtimes map[string]string `json:"runtimes"`
+	LastUsed time.Time         `json:"lastUsed"`
+}
+
+// UsageKey identifies an install in Usage.Installs by its directory name,
+// with or without a leading "v".
+func UsageKey(module, version string) string {
+	return module + "/" + strings.TrimPrefix(version, "v")
+}
+
+func (s *State) Usage() (Usage, error) {
+	usage := Usage{
+		Projects: make(map[string]ProjectUsage),
+		Installs: make(map[string]time.Time),
+	}
+
+	data, err := os.ReadFile(s.usagePath())
+	if err != nil {
+		if isNotExist(err) {
+			return usage, nil
+		}
+		return usage, errors.NewFileSystemError("failed to read runtime usage", err)
+	}
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return usage, errors.NewFileSystemError("failed to parse runtime usage", err)
+	}
+	if usage.Projects == nil {
+		usage.Projects = make(map[string]ProjectUsage)
+	}
+	if usage.Installs == nil {
+		usage.Installs = make(map[string]time.Time)
+	}
+	return usage, nil
+}
+
+// RecordUsage marks the install of module as used now. With a project config
+// path, the project is also recorded as referencing it.
+func (s *State) RecordUsage(module, version, configPath string) error {
+	return s.updateUsage(func(usage *Usage) bool {
+		now := time.Now().UTC()
+		usage.Installs[UsageKey(module, version)] = now
+		if configPath != "" {
+			project := usage.Projects[configPath]
+			if project.Runtimes == nil {
+				project.Runtimes = make(map[string]string)
+			}
+			project.Runtimes[module] = strings.TrimPrefix(version, "v")
+			project.LastUsed = now
+			usage.Projects[configPath] = project
+		}
+		return true
+	})
+}
+
+// ForgetProject drops a project whose config no longer exists.
+func (s *State) ForgetProject(configPath string) error {
+	return s.updateUsage(func(usage *Usage) bool {
+		if _, ok := usage.Projects[configPath]; !ok {
+			return false
+		}
+		delete(usage.Projects, configPath)
+		return true
+	})
+}
+
+// ForgetInstall drops the usage of an install that has been removed.
+func (s *State) ForgetInstall(module, version string) error {
+	return s.updateUsage(func(usage *Usage) bool {
+		delete(usage.Installs, UsageKey(module, version))
+		return true
+	})
+}
+
+// updateUsage applies change to the recorded usage under the lock, writing
+// it back when change reports a modification.
+func (s *State) updateUsage(change func(usage *Usage) bool) error {
+	return s.update(func() error {
+		usage, err := s.Usage()
+		if err != nil {
+			return err
+		}
+		if !change(&usage) {
+			return nil
+		}
+
+		data, err := json.MarshalIndent(usage, "", "  ")
+		if err != nil {
+			return errors.NewFileSystemError("failed to encode runtime usage", err)
+		}
+		return s.writeFile(s.usagePath(), data, "runtime usage")
+	})
+}
+
+func (s *State) usagePath() string {
+	return filepath.Join(s.currentRoot, usageFileName)
+}